@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/jobs"
+	"github.com/google/uuid"
+)
+
+// Background jobs are the side effects the requests don't wait for (notifications, fan-outs), run by the
+// internal/jobs runner out of the background_jobs table
+
+const (
+	// jobNotification creates a notification => notificationJob
+	jobNotification = "notification"
+	// jobChirpPublished pushes a published chirp to the followers' streams & the webhooks => chirpPublishedJob
+	jobChirpPublished = "chirp_published"
+)
+
+type notificationJob struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Type    string    `json:"type"`
+	ActorID uuid.UUID `json:"actor_id"`
+}
+
+type chirpPublishedJob struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	UserID  uuid.UUID `json:"user_id"`
+}
+
+// enqueueJob stores a job for the workers.
+// Given the queries of a transaction, the job is only queued if the changes it follows up on are committed.
+func enqueueJob(ctx context.Context, q *database.Queries, kind string, args any) error {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	return q.CreateBackgroundJob(ctx, database.CreateBackgroundJobParams{
+		ID:      uuid.New(),
+		Kind:    kind,
+		Payload: payload,
+		RunAt:   time.Now(),
+	})
+}
+
+// jobStore keeps the background jobs in Postgres, a claim locks its batch with FOR UPDATE SKIP LOCKED
+type jobStore struct {
+	db *database.Queries
+}
+
+func (s jobStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]jobs.Job, error) {
+	claimed, err := s.db.ClaimDueBackgroundJobs(ctx, database.ClaimDueBackgroundJobsParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		BatchSize:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claimedJobs := make([]jobs.Job, len(claimed))
+	for i, job := range claimed {
+		claimedJobs[i] = jobs.Job{
+			ID:       job.ID,
+			Kind:     job.Kind,
+			Payload:  job.Payload,
+			Attempts: int(job.Attempts),
+		}
+	}
+	return claimedJobs, nil
+}
+
+func (s jobStore) Retry(ctx context.Context, job jobs.Job, runAt time.Time, lastError string) error {
+	updated, err := s.db.RetryBackgroundJob(ctx, database.RetryBackgroundJobParams{
+		RunAt:     runAt,
+		LastError: lastError,
+		ID:        job.ID,
+		Attempts:  int32(job.Attempts),
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return jobs.ErrLeaseLost
+	}
+	return nil
+}
+
+func (s jobStore) Discard(ctx context.Context, job jobs.Job) error {
+	return completeJob(ctx, s.db, job)
+}
+
+// completeJob removes the job once its work is done, in the transaction of the work given its queries
+func completeJob(ctx context.Context, q *database.Queries, job jobs.Job) error {
+	deleted, err := q.DeleteBackgroundJob(ctx, database.DeleteBackgroundJobParams{
+		ID:       job.ID,
+		Attempts: int32(job.Attempts),
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return jobs.ErrLeaseLost
+	}
+	return nil
+}
+
+// runJob does the work of the job and removes it in one transaction, so it's never done twice
+func (cfg *apiConfig) runJob(ctx context.Context, job jobs.Job) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	switch job.Kind {
+	case jobNotification:
+		var args notificationJob
+		if err := json.Unmarshal(job.Payload, &args); err != nil {
+			return err
+		}
+		err = cfg.createNotification(ctx, qtx, args.UserID, args.Type, args.ActorID)
+	case jobChirpPublished:
+		var args chirpPublishedJob
+		if err := json.Unmarshal(job.Payload, &args); err != nil {
+			return err
+		}
+		err = cfg.fanOutChirp(ctx, qtx, args.ChirpID, args.UserID)
+	default:
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := completeJob(ctx, qtx, job); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// fanOutChirp stores the chirp.created events of the followers and the webhook deliveries
+func (cfg *apiConfig) fanOutChirp(ctx context.Context, q *database.Queries, chirpID, userID uuid.UUID) error {
+	chirp, err := q.GetChirpyByUserID(ctx, database.GetChirpyByUserIDParams{
+		ID:     chirpID,
+		UserID: userID,
+	})
+	// deleted in the meantime, there's nothing to announce anymore
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	attachments, err := q.GetMediaAttachmentsByChirpIDs(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.notifyFollowers(ctx, q, chirp, attachments); err != nil {
+		return err
+	}
+	return createWebhookDeliveries(ctx, q, eventChirpCreated, chirp.UserID, cfg.chirpResponse(chirp, attachments))
+}
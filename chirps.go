@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/media"
+	"github.com/google/uuid"
+)
+
+// chirpInput is a chirp to create, it goes through the same pipeline whether it comes from
+// POST /api/chirps or from a published draft
+type chirpInput struct {
+	Body string
+	// media pre-uploaded to POST /api/media
+	MediaIDs []uuid.UUID
+	// images sent along with the chirp, only stored once the chirp passed the validation
+	Uploads [][]byte
+	// [Optional] schedules the chirp
+	PublishAt *time.Time
+}
+
+// chirpError is a pipeline failure caused by the request itself, Status is the response code to use
+type chirpError struct {
+	Status  int
+	Message string
+}
+
+func (e *chirpError) Error() string {
+	return e.Message
+}
+
+// writeChirpError writes the pipeline error, anything but a chirpError is logged and hidden behind fallback
+func writeChirpError(rw http.ResponseWriter, err error, fallback string) {
+	var chirpErr *chirpError
+	if errors.As(err, &chirpErr) {
+		writeErrorResponse(rw, chirpErr.Status, chirpErr.Message)
+		return
+	}
+
+	log.Printf("%s: %v", fallback, err)
+	writeErrorResponse(rw, 500, fallback)
+}
+
+// createChirp validates & filters the chirp, then stores it with its attachments in a single transaction.
+// withTx runs inside that transaction (nil to skip it), for callers with their own changes to commit along.
+func (cfg *apiConfig) createChirp(ctx context.Context, user database.User, input chirpInput, withTx func(qtx *database.Queries) error) (database.Chirp, []database.MediaAttachment, error) {
+	if len(input.MediaIDs)+len(input.Uploads) > maxChirpAttachments {
+		return database.Chirp{}, nil, &chirpError{400, fmt.Sprintf("a chirp can have at most %d images", maxChirpAttachments)}
+	}
+
+	// scheduled chirps are made visible by the publisher worker, the others right away
+	publishAt := sql.NullTime{}
+	publishedAt := sql.NullTime{Time: time.Now(), Valid: true}
+	if input.PublishAt != nil {
+		if !input.PublishAt.After(time.Now()) {
+			return database.Chirp{}, nil, &chirpError{400, "publish_at must be in the future"}
+		}
+		if input.PublishAt.After(time.Now().Add(maxScheduleAhead)) {
+			return database.Chirp{}, nil, &chirpError{400, "publish_at can't be more than a year ahead"}
+		}
+		// stored in the server's local time, like every other timestamp
+		publishAt = sql.NullTime{Time: input.PublishAt.Local(), Valid: true}
+		publishedAt = sql.NullTime{}
+	}
+
+	// Validate chirp body
+	if err := cfg.validateChirpBody(input.Body, user); err != nil {
+		return database.Chirp{}, nil, &chirpError{400, err.Error()}
+	}
+
+	// Run the post content through the content filter
+	filtered := cfg.contentFilter.Apply(input.Body)
+	if filtered.Rejected {
+		return database.Chirp{}, nil, &chirpError{400, "chirp contains blocked content"}
+	}
+
+	// Store the images sent along with the chirp, they get attached like the pre-uploaded ones.
+	// They're discarded if the chirp isn't created, the request may be cancelled by then
+	mediaUUIDs := append([]uuid.UUID{}, input.MediaIDs...)
+	var uploaded []database.MediaAttachment
+	committed := false
+	defer func() {
+		if !committed && len(uploaded) > 0 {
+			cfg.discardMedia(context.WithoutCancel(ctx), uploaded)
+		}
+	}()
+	for _, data := range input.Uploads {
+		attachment, err := cfg.storeMedia(ctx, user.ID, data)
+		if errors.Is(err, media.ErrUnsupportedType) {
+			return database.Chirp{}, nil, &chirpError{415, err.Error()}
+		}
+		if errors.Is(err, media.ErrTooManyPixels) || errors.Is(err, media.ErrTooManyFrames) {
+			return database.Chirp{}, nil, &chirpError{400, err.Error()}
+		}
+		if err != nil {
+			return database.Chirp{}, nil, fmt.Errorf("couldn't store the media: %w", err)
+		}
+		uploaded = append(uploaded, attachment)
+		mediaUUIDs = append(mediaUUIDs, attachment.ID)
+	}
+
+	// the chirp & its attachments are stored together, or not at all
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.CreateChirpy(ctx, database.CreateChirpyParams{
+		ID:          uuid.New(),
+		Body:        filtered.Body,
+		UserID:      user.ID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		PublishAt:   publishAt,
+		PublishedAt: publishedAt,
+	})
+	if err != nil {
+		return database.Chirp{}, nil, err
+	}
+
+	for position, mediaUUID := range mediaUUIDs {
+		attached, err := qtx.AttachMediaToChirp(ctx, database.AttachMediaToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(position),
+			ID:       mediaUUID,
+			UserID:   user.ID,
+		})
+		if err != nil {
+			return database.Chirp{}, nil, err
+		}
+		// only the uploader's own, not yet attached media can be used
+		if attached == 0 {
+			return database.Chirp{}, nil, &chirpError{400, fmt.Sprintf("media %s not found or already attached", mediaUUID)}
+		}
+	}
+
+	if withTx != nil {
+		if err := withTx(qtx); err != nil {
+			return database.Chirp{}, nil, err
+		}
+	}
+
+	// the followers' streams & the webhooks are fed by a background job, queued along with the chirp.
+	// Scheduled chirps get theirs once the publisher worker publishes them.
+	if chirp.PublishedAt.Valid {
+		err = enqueueJob(ctx, qtx, jobChirpPublished, chirpPublishedJob{ChirpID: chirp.ID, UserID: chirp.UserID})
+		if err != nil {
+			return database.Chirp{}, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.Chirp{}, nil, err
+	}
+	committed = true
+
+	// flagged chirps are published, and queued for the moderators
+	if filtered.Flagged {
+		if err := cfg.flagChirpForReview(ctx, chirp, filtered); err != nil {
+			log.Printf("couldn't flag chirp %s for review: %v", chirp.ID, err)
+		}
+	}
+
+	attachments, err := cfg.db.GetMediaAttachmentsByChirpIDs(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return database.Chirp{}, nil, err
+	}
+
+	return chirp, attachments, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/contentfilter"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// dbRuleSource loads the content filter rules managed by the admins
+type dbRuleSource struct {
+	db *database.Queries
+}
+
+func (s dbRuleSource) LoadRules(ctx context.Context) ([]contentfilter.Rule, error) {
+	dbRules, err := s.db.ListContentFilterRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]contentfilter.Rule, len(dbRules))
+	for i, dbRule := range dbRules {
+		rules[i] = contentfilter.Rule{
+			Pattern: dbRule.Pattern,
+			Action:  contentfilter.Action(dbRule.Action),
+		}
+	}
+
+	return rules, nil
+}
+
+// newContentFilter builds the filter from the DB rules, plus the rules of the optional file
+func newContentFilter(db *database.Queries, rulesFile string) *contentfilter.Filter {
+	var source contentfilter.RuleSource = dbRuleSource{db: db}
+	if rulesFile != "" {
+		source = contentfilter.MultiSource{source, contentfilter.FileSource{Path: rulesFile}}
+	}
+
+	return contentfilter.New(source)
+}
+
+// reloadContentFilterEvery picks up rule changes made outside this instance (other instances, the file, the DB)
+func reloadContentFilterEvery(ctx context.Context, filter *contentfilter.Filter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := filter.Reload(ctx); err != nil {
+				log.Printf("couldn't reload the content filter, keeping the current rules: %v", err)
+			}
+		}
+	}
+}
+
+// flagChirpForReview queues the chirp in the moderation queue as an auto_flagged report, listing the matched rules
+func (cfg *apiConfig) flagChirpForReview(ctx context.Context, chirp database.Chirp, result contentfilter.Result) error {
+	matched := []string{}
+	for _, match := range result.Matches {
+		if match.Rule.Action == contentfilter.ActionFlag {
+			matched = append(matched, fmt.Sprintf("%q", match.Rule.Pattern))
+		}
+	}
+
+	_, err := cfg.db.CreateChirpReport(ctx, database.CreateChirpReportParams{
+		ID:            uuid.New(),
+		ChirpID:       uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpAuthorID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		ChirpBody:     chirp.Body,
+		Reason:        "auto_flagged",
+		Details:       "matched content filter rules: " + strings.Join(matched, ", "),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	})
+	return err
+}
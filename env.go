@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment helper functions, used while building apiConfig in main
+
+// envString returns the value of the env variable, or fallback when it's unset
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt returns the env variable parsed as an int, or fallback when it's unset or invalid
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("invalid %s=%q, using the default %d", key, value, fallback)
+		return fallback
+	}
+
+	return parsed
+}
+
+// envDuration returns the env variable parsed as a duration ("30s", "5m"), or fallback when it's unset or invalid.
+// Zero and negative durations are invalid, they'd make the tickers panic and the windows meaningless
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		log.Printf("invalid %s=%q, using the default %s", key, value, fallback)
+		return fallback
+	}
+
+	return parsed
+}
+
+// envBool returns the env variable parsed as a bool ("true", "1", ...), or fallback when it's unset or invalid
+func envBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("invalid %s=%q, using the default %t", key, value, fallback)
+		return fallback
+	}
+
+	return parsed
+}
@@ -3,9 +3,14 @@ module github.com/MeYo0o/chirpy_server
 go 1.25.0
 
 require (
+	github.com/coder/websocket v1.8.15
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/crypto v0.42.0
+	golang.org/x/image v0.32.0
 )
+
+require golang.org/x/sys v0.36.0 // indirect
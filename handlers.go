@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/MeYo0o/chirpy_server/internal/auth"
 	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/webhooks"
 	"github.com/google/uuid"
 )
 
@@ -29,6 +37,34 @@ func handlerHealth(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// chirpResponse is the public view of a chirp, with its media attachments
+func (cfg *apiConfig) chirpResponse(chirp database.Chirp, attachments []database.MediaAttachment) map[string]any {
+	attachmentsResponseJson := make([]map[string]any, len(attachments))
+	for i, attachment := range attachments {
+		attachmentsResponseJson[i] = cfg.mediaAttachmentResponse(attachment)
+	}
+
+	// both are null for a chirp published right away / one still scheduled
+	var publishAt, publishedAt *time.Time
+	if chirp.PublishAt.Valid {
+		publishAt = &chirp.PublishAt.Time
+	}
+	if chirp.PublishedAt.Valid {
+		publishedAt = &chirp.PublishedAt.Time
+	}
+
+	return map[string]any{
+		"id":           chirp.ID,
+		"body":         chirp.Body,
+		"user_id":      chirp.UserID,
+		"created_at":   chirp.CreatedAt,
+		"updated_at":   chirp.UpdatedAt,
+		"publish_at":   publishAt,
+		"published_at": publishedAt,
+		"attachments":  attachmentsResponseJson,
+	}
+}
+
 func (cfg *apiConfig) handlerGetChirps(rw http.ResponseWriter, r *http.Request) {
 	var chirps []database.Chirp
 	var err error
@@ -43,7 +79,15 @@ func (cfg *apiConfig) handlerGetChirps(rw http.ResponseWriter, r *http.Request)
 		sort = optionalSort
 	}
 
+	// blocks & mutes of the viewer apply to the feed
+	viewerID, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
 	// we get all chirps/posts of that provided author_id/user_id
+	var pinnedChirpID uuid.NullUUID
 	authorID := urlValues.Get("author_id")
 	if authorID != "" {
 		userUUID, err := validateUUID(authorID, "author_id")
@@ -52,36 +96,62 @@ func (cfg *apiConfig) handlerGetChirps(rw http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		chirps, err = cfg.db.GetChirpsByUserID(r.Context(), userUUID)
+		// an unknown author simply has no chirps
+		author, err := cfg.db.GetUserByID(r.Context(), userUUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			writeErrorResponse(rw, 500, "couldn't fetch the author")
+			return
+		}
+		pinnedChirpID = author.PinnedChirpID
+
+		chirps, err = cfg.db.GetChirpsByUserID(r.Context(), database.GetChirpsByUserIDParams{
+			UserID:   userUUID,
+			ViewerID: viewerID,
+		})
 		if err != nil {
 			writeErrorResponse(rw, 403, fmt.Sprintf("couldn't fetch chirps: %v", err))
 			return
 		}
 	} else {
-		chirps, err = cfg.db.GetChirps(r.Context())
+		chirps, err = cfg.db.GetChirps(r.Context(), viewerID)
 		if err != nil {
 			writeErrorResponse(rw, 403, fmt.Sprintf("couldn't fetch chirps: %v", err))
 			return
 		}
 	}
 
-	// sort chirps slice in memory in terms of "created_at"
+	// sort chirps slice in memory in terms of "published_at" => scheduled chirps take their place once published
 	slices.SortFunc(chirps, func(a, b database.Chirp) int {
 		if sort == "asc" {
-			return a.CreatedAt.Compare(b.CreatedAt)
+			return a.PublishedAt.Time.Compare(b.PublishedAt.Time)
 		}
 
-		return b.CreatedAt.Compare(a.CreatedAt)
+		return b.PublishedAt.Time.Compare(a.PublishedAt.Time)
 	})
 
-	chirpsResponseJson := make([]map[string]string, len(chirps))
+	// the viewer's muted words can't be matched in SQL, they're applied to the fetched chirps
+	if viewerID.Valid {
+		chirps, err = cfg.withoutMutedWords(r.Context(), viewerID.UUID, chirps)
+		if err != nil {
+			writeErrorResponse(rw, 500, "couldn't apply the muted words")
+			return
+		}
+	}
+
+	// on an author's profile, their pinned chirp comes first
+	chirps = pinnedChirpFirst(chirps, pinnedChirpID)
+
+	attachments, err := cfg.chirpsAttachments(r.Context(), chirps)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirps' media")
+		return
+	}
+
+	chirpsResponseJson := make([]map[string]any, len(chirps))
 	for i, chirpy := range chirps {
-		chirpsResponseJson[i] = map[string]string{
-			"id":         chirpy.UserID.String(),
-			"created_at": chirpy.CreatedAt.String(),
-			"updated_at": chirpy.UpdatedAt.String(),
-			"body":       chirpy.Body,
-			"user_id":    chirpy.UserID.String(),
+		chirpsResponseJson[i] = cfg.chirpResponse(chirpy, attachments[chirpy.ID])
+		if authorID != "" {
+			chirpsResponseJson[i]["pinned"] = pinnedChirpID.Valid && chirpy.ID == pinnedChirpID.UUID
 		}
 	}
 
@@ -99,21 +169,43 @@ func (cfg *apiConfig) handlerGetSingleChirp(rw http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Get the chirp from database
-	chirp, err := cfg.db.GetChirpy(r.Context(), chirpUUID)
+	viewerID, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// Get the chirp from database => users blocked by the author, and non-followers of a protected author, don't see it
+	chirp, err := cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpUUID,
+		ViewerID: viewerID,
+	})
 	if err != nil {
 		writeErrorResponse(rw, 404, "Chirp not found")
 		return
 	}
 
+	// a chirp matching the viewer's muted words is hidden like in the feed
+	if viewerID.Valid {
+		visible, err := cfg.withoutMutedWords(r.Context(), viewerID.UUID, []database.Chirp{chirp})
+		if err != nil {
+			writeErrorResponse(rw, 500, "couldn't apply the muted words")
+			return
+		}
+		if len(visible) == 0 {
+			writeErrorResponse(rw, 404, "Chirp not found")
+			return
+		}
+	}
+
+	attachments, err := cfg.db.GetMediaAttachmentsByChirpIDs(r.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirp's media")
+		return
+	}
+
 	// Return the chirp
-	writeSuccessResponse(rw, 200, map[string]any{
-		"id":         chirp.ID,
-		"body":       chirp.Body,
-		"user_id":    chirp.UserID,
-		"created_at": chirp.CreatedAt,
-		"updated_at": chirp.UpdatedAt,
-	})
+	writeSuccessResponse(rw, 200, cfg.chirpResponse(chirp, attachments))
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(rw http.ResponseWriter, r *http.Request) {
@@ -142,19 +234,71 @@ func (cfg *apiConfig) handlerDeleteChirp(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Delete the chirp
-	err = cfg.db.DeleteChirp(r.Context(), database.DeleteChirpParams{
-		ID:     chirpUUID,
-		UserID: userUUID,
+	// the webhook deliveries are created with the deletion, so neither happens without the other
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't delete this chirpy")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Delete the chirp => soft delete, it can be restored during the grace period, then the retention job purges it
+	_, err = qtx.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{
+		DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		DeletedBy: uuid.NullUUID{UUID: userUUID, Valid: true},
+		UpdatedAt: time.Now(),
+		ID:        chirpUUID,
+		UserID:    userUUID,
 	})
 	if err != nil {
 		writeErrorResponse(rw, 403, "couldn't delete this chirpy")
 		return
 	}
 
+	err = createWebhookDeliveries(r.Context(), qtx, eventChirpDeleted, userUUID, map[string]any{
+		"id":      chirpUUID,
+		"user_id": userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't delete this chirpy")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(rw, 500, "couldn't delete this chirpy")
+		return
+	}
+
 	writeEmptyResponse(rw, 204)
 }
 
+const (
+	// Polka signs its webhooks with these headers => hex HMAC-SHA256 of "<timestamp>.<raw body>"
+	polkaTimestampHeader = "X-Polka-Timestamp"
+	polkaSignatureHeader = "X-Polka-Signature"
+	polkaMaxBodyBytes    = 1 << 20
+)
+
+// verifyPolkaRequest authenticates a Polka webhook by its signature, against the current or the previous secret
+// during a rotation. Unsigned requests fall back to the static API key, unless signatures are required.
+func (cfg *apiConfig) verifyPolkaRequest(r *http.Request, body []byte) error {
+	signature := r.Header.Get(polkaSignatureHeader)
+	if signature != "" || cfg.polkaRequireSignature {
+		if len(cfg.polkaSecrets) == 0 {
+			return fmt.Errorf("signed webhooks aren't configured")
+		}
+		return webhooks.Verify(cfg.polkaSecrets, r.Header.Get(polkaTimestampHeader), signature, body, time.Now(), cfg.polkaSignatureWindow)
+	}
+
+	// constant-time, the comparison time mustn't tell how much of the key was right
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || cfg.polkaKey == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaKey)) != 1 {
+		return fmt.Errorf("unauthorized APIKey")
+	}
+	return nil
+}
+
 func (cfg *apiConfig) handlerPolkaWebhooks(rw http.ResponseWriter, r *http.Request) {
 	type WebhooksData struct {
 		UserID string `json:"user_id"`
@@ -167,20 +311,24 @@ func (cfg *apiConfig) handlerPolkaWebhooks(rw http.ResponseWriter, r *http.Reque
 
 	var webhooksReq WebhooksRequest
 
-	// Extract the API key and validate it
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil || apiKey != cfg.polkaKey {
-		writeErrorResponse(rw, 401, "unauthorized APIKey")
+	// the signature covers the raw body, it's read as is before being decoded
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, polkaMaxBodyBytes))
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := cfg.verifyPolkaRequest(r, body); err != nil {
+		writeErrorResponse(rw, 401, err.Error())
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&webhooksReq)
+	err = json.Unmarshal(body, &webhooksReq)
 	if err != nil || webhooksReq.Event == "" || webhooksReq.Data.UserID == "" {
 		writeErrorResponse(rw, 400, "invalid request")
 		return
 	}
-	defer r.Body.Close()
 
 	if webhooksReq.Event == "user.upgraded" {
 		userUUID, err := validateUUID(webhooksReq.Data.UserID, "user ID")
@@ -189,14 +337,12 @@ func (cfg *apiConfig) handlerPolkaWebhooks(rw http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		_, err = cfg.db.GetUserByID(r.Context(), userUUID)
-		if err != nil {
+		if _, err := cfg.db.GetUserByID(r.Context(), userUUID); err != nil {
 			writeErrorResponse(rw, 404, "user not found")
 			return
 		}
 
-		err = cfg.db.UpgradeUserToRed(r.Context(), userUUID)
-		if err != nil {
+		if err := cfg.upgradeUserToRed(r.Context(), userUUID); err != nil {
 			writeErrorResponse(rw, 403, "couldn't upgrade the user")
 			return
 		}
@@ -205,58 +351,133 @@ func (cfg *apiConfig) handlerPolkaWebhooks(rw http.ResponseWriter, r *http.Reque
 	writeEmptyResponse(rw, 204)
 }
 
+// upgradeUserToRed gives the user Chirpy Red, with the notification & the webhook deliveries in the same transaction.
+// Polka retries its webhooks, only the first delivery is confirmed to the user.
+func (cfg *apiConfig) upgradeUserToRed(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	upgraded, err := qtx.UpgradeUserToRed(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if upgraded == 0 {
+		return nil
+	}
+
+	err = enqueueJob(ctx, qtx, jobNotification, notificationJob{UserID: userID, Type: notificationChirpyRed})
+	if err != nil {
+		return err
+	}
+	err = createWebhookDeliveries(ctx, qtx, eventUserUpgraded, userID, map[string]any{
+		"user_id": userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (cfg *apiConfig) handlerCreateChirp(rw http.ResponseWriter, r *http.Request) {
 	type ChirpyPostReq struct {
 		Body string `json:"body"`
+		// images pre-uploaded to POST /api/media
+		MediaIDs []string `json:"media_ids"`
+		// [Optional] schedules the chirp, it stays hidden until then
+		PublishAt *time.Time `json:"publish_at"`
 	}
 	chirpyPostReq := ChirpyPostReq{}
 
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&chirpyPostReq)
+	// Validate JWT and get the user => their tier sets the length limit.
+	// Before reading the body, so only logged-in users get to upload
+	user, err := cfg.authenticateUser(r)
 	if err != nil {
-		writeErrorResponse(rw, 400, "Something went wrong")
+		writeErrorResponse(rw, 401, err.Error())
 		return
 	}
-	defer r.Body.Close()
 
-	// Validate chirp body
-	if err := validateChirpBody(chirpyPostReq.Body); err != nil {
-		writeErrorResponse(rw, 400, err.Error())
-		return
-	}
+	// the images can also come with the chirp itself, as a multipart form with "body" & "media" fields
+	var uploads [][]byte
 
-	// Validate JWT and get user UUID
-	userUUID, err := cfg.validateJWTFromRequest(r)
-	if err != nil {
-		writeErrorResponse(rw, 401, err.Error())
-		return
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(rw, r.Body, maxChirpAttachments*cfg.mediaMaxBytes+multipartOverhead)
+		if err := r.ParseMultipartForm(multipartOverhead); err != nil {
+			writeErrorResponse(rw, 400, "invalid multipart form, or the images are too large")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		chirpyPostReq.Body = r.FormValue("body")
+		chirpyPostReq.MediaIDs = r.MultipartForm.Value["media_ids"]
+		if publishAtStr := r.FormValue("publish_at"); publishAtStr != "" {
+			publishAt, err := time.Parse(time.RFC3339, publishAtStr)
+			if err != nil {
+				writeErrorResponse(rw, 400, "publish_at must be an RFC 3339 timestamp")
+				return
+			}
+			chirpyPostReq.PublishAt = &publishAt
+		}
+
+		fileHeaders := r.MultipartForm.File["media"]
+		if len(fileHeaders) > maxChirpAttachments {
+			writeErrorResponse(rw, 400, fmt.Sprintf("a chirp can have at most %d images", maxChirpAttachments))
+			return
+		}
+		for _, fileHeader := range fileHeaders {
+			data, err := readUpload(fileHeader, cfg.mediaMaxBytes)
+			if err != nil {
+				writeErrorResponse(rw, 400, err.Error())
+				return
+			}
+			uploads = append(uploads, data)
+		}
+	} else {
+		r.Body = http.MaxBytesReader(rw, r.Body, multipartOverhead)
+		decoder := json.NewDecoder(r.Body)
+		err := decoder.Decode(&chirpyPostReq)
+		if err != nil {
+			writeErrorResponse(rw, 400, "Something went wrong")
+			return
+		}
 	}
+	defer r.Body.Close()
 
-	// Clean the post content
-	cleanedPost := cleanChirpContent(chirpyPostReq.Body)
+	mediaUUIDs := make([]uuid.UUID, 0, len(chirpyPostReq.MediaIDs))
+	for _, mediaID := range chirpyPostReq.MediaIDs {
+		mediaUUID, err := validateUUID(mediaID, "media ID")
+		if err != nil {
+			writeErrorResponse(rw, 400, err.Error())
+			return
+		}
+		mediaUUIDs = append(mediaUUIDs, mediaUUID)
+	}
 
-	chirpyPost, err := cfg.db.CreateChirpy(r.Context(), database.CreateChirpyParams{
-		ID:        uuid.New(),
-		Body:      cleanedPost,
-		UserID:    userUUID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
+	chirpyPost, attachments, err := cfg.createChirp(r.Context(), user, chirpInput{
+		Body:      chirpyPostReq.Body,
+		MediaIDs:  mediaUUIDs,
+		Uploads:   uploads,
+		PublishAt: chirpyPostReq.PublishAt,
+	}, nil)
 	if err != nil {
-		writeErrorResponse(rw, 403, fmt.Sprintf("couldn't create a chirpy: %v", err))
+		writeChirpError(rw, err, "couldn't create the chirp")
 		return
 	}
 
-	writeSuccessResponse(rw, 201, map[string]any{
-		"id":         chirpyPost.ID,
-		"body":       chirpyPost.Body,
-		"user_id":    chirpyPost.UserID,
-		"created_at": chirpyPost.CreatedAt,
-		"updated_at": chirpyPost.UpdatedAt,
-	})
+	writeSuccessResponse(rw, 201, cfg.chirpResponse(chirpyPost, attachments))
 }
 
 // Response helper functions
+
+// writePasswordBusyResponse answers a request whose password couldn't be hashed for lack of a free slot
+func writePasswordBusyResponse(rw http.ResponseWriter) {
+	rw.Header().Set("Retry-After", "1")
+	writeErrorResponse(rw, 503, auth.ErrPasswordBusy.Error())
+}
 func encodeJson(params map[string]any) ([]byte, error) {
 	return json.Marshal(params)
 }
@@ -289,24 +510,118 @@ func writeSuccessResponse(rw http.ResponseWriter, statusCode int, data map[strin
 	writeJSONResponse(rw, statusCode, data)
 }
 
+// fieldError describes why a single request field was rejected
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeValidationErrorResponse writes a 400 response listing every rejected field
+func writeValidationErrorResponse(rw http.ResponseWriter, fieldErrors []fieldError) {
+	writeJSONResponse(rw, 400, map[string]any{
+		"error":  "validation failed",
+		"fields": fieldErrors,
+	})
+}
+
 // writeEmptyResponse writes an empty response with the given status code
 func writeEmptyResponse(rw http.ResponseWriter, statusCode int) {
 	rw.WriteHeader(statusCode)
 }
 
 // Authentication helper functions
-func (cfg *apiConfig) validateJWTFromRequest(r *http.Request) (uuid.UUID, error) {
+var (
+	errAccountSuspended      = errors.New("unauthorized: account is suspended")
+	errPasswordResetRequired = errors.New("unauthorized: password reset required")
+)
+
+// authenticateUser validates the request JWT and loads its user, suspended users are refused.
+// Users that were asked to reset their password get errPasswordResetRequired along with the user,
+// so the password update endpoint can still let them through.
+func (cfg *apiConfig) authenticateUser(r *http.Request) (database.User, error) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil || token == "" {
-		return uuid.Nil, fmt.Errorf("unauthorized: invalid user JWT")
+		return database.User{}, fmt.Errorf("unauthorized: invalid user JWT")
 	}
 
 	userUUID, err := auth.ValidateJWT(token, cfg.jwtSecret)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("unauthorized: %v", err)
+		return database.User{}, fmt.Errorf("unauthorized: %v", err)
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		return database.User{}, fmt.Errorf("unauthorized: user not found")
+	}
+
+	if user.SuspendedAt.Valid {
+		return database.User{}, errAccountSuspended
+	}
+
+	if user.PasswordResetRequired {
+		return user, errPasswordResetRequired
+	}
+
+	return user, nil
+}
+
+func (cfg *apiConfig) validateJWTFromRequest(r *http.Request) (uuid.UUID, error) {
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
+
+// viewerFromRequest returns the authenticated user, or null for an anonymous request.
+// A token that's sent but invalid is still an error, the client expects to see the feed as itself.
+func (cfg *apiConfig) viewerFromRequest(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+
+	return uuid.NullUUID{UUID: userUUID, Valid: true}, nil
+}
+
+// clientIPFromRequest returns the IP of the direct peer, forwarding headers are not trusted
+// because any client could set them to dodge the per-IP throttling
+func clientIPFromRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parsePagination reads the optional limit & offset query params
+func parsePagination(r *http.Request, defaultLimit, maxLimit int32) (int32, int32, error) {
+	limit, offset := defaultLimit, int32(0)
+	urlValues := r.URL.Query()
+
+	if limitStr := urlValues.Get("limit"); limitStr != "" {
+		parsed, err := strconv.ParseInt(limitStr, 10, 32)
+		if err != nil || parsed < 1 {
+			return 0, 0, fmt.Errorf("invalid limit")
+		}
+		limit = min(int32(parsed), maxLimit)
+	}
+
+	if offsetStr := urlValues.Get("offset"); offsetStr != "" {
+		parsed, err := strconv.ParseInt(offsetStr, 10, 32)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("invalid offset")
+		}
+		offset = int32(parsed)
 	}
 
-	return userUUID, nil
+	return limit, offset, nil
 }
 
 // Request validation helper functions
@@ -319,12 +634,50 @@ func validateRequiredFields(fields map[string]string) error {
 	return nil
 }
 
-func validateChirpBody(body string) error {
-	if body == "" {
+// validatePassword checks the password against the configured policy,
+// the returned field errors are empty when the password is acceptable
+func (cfg *apiConfig) validatePassword(password string) ([]fieldError, error) {
+	violations, err := cfg.passwordPolicy.Validate(password)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldErrors := make([]fieldError, len(violations))
+	for i, violation := range violations {
+		fieldErrors[i] = fieldError{
+			Field:   "password",
+			Code:    violation.Code,
+			Message: violation.Message,
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+// maxTextBytes is a hard cap on the size of a chirp or a message, checked before counting the characters:
+// a link counts as a fixed length and a character can take many bytes, the count alone doesn't bound the size
+const maxTextBytes = 8 << 10
+
+// chirpLengthLimit returns the chirp length limit of the user's tier
+func (cfg *apiConfig) chirpLengthLimit(user database.User) int {
+	if user.IsChirpyRed {
+		return cfg.chirpMaxLengthRed
+	}
+	return cfg.chirpMaxLength
+}
+
+func (cfg *apiConfig) validateChirpBody(body string, user database.User) error {
+	if strings.TrimSpace(body) == "" {
 		return fmt.Errorf("chirp body cannot be empty")
 	}
-	if len(body) > 140 {
-		return fmt.Errorf("chirp body too long")
+	if len(body) > maxTextBytes {
+		return fmt.Errorf("chirp body too large: the limit is %d bytes", maxTextBytes)
+	}
+
+	// counted in characters as the reader sees them, not in bytes
+	length, maxLength := cfg.chirpCounter.Count(body), cfg.chirpLengthLimit(user)
+	if length > maxLength {
+		return fmt.Errorf("chirp body too long: %d characters, the limit is %d", length, maxLength)
 	}
 	return nil
 }
@@ -342,23 +695,6 @@ func validateUUID(uuidStr, fieldName string) (uuid.UUID, error) {
 	return parsedUUID, nil
 }
 
-func cleanChirpContent(body string) string {
-	// prevent these words - has to be without "!"
-	preventedKeywords := []string{"kerfuffle", "Kerfuffle", "sharbert", "Sharbert", "fornax", "Fornax"}
-
-	keywordsToCheck := strings.Split(body, " ")
-
-	for i, keyword := range keywordsToCheck {
-		for _, preventedKeyword := range preventedKeywords {
-			if keyword == preventedKeyword {
-				keywordsToCheck[i] = "****"
-			}
-		}
-	}
-
-	return strings.Join(keywordsToCheck, " ")
-}
-
 func (cfg *apiConfig) handlerCreateUser(rw http.ResponseWriter, r *http.Request) {
 	type emailRequest struct {
 		Password string `json:"password"`
@@ -384,7 +720,22 @@ func (cfg *apiConfig) handlerCreateUser(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(emailReq.Password)
+	// Enforce the password policy
+	passwordErrors, err := cfg.validatePassword(emailReq.Password)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't validate the password")
+		return
+	}
+	if len(passwordErrors) > 0 {
+		writeValidationErrorResponse(rw, passwordErrors)
+		return
+	}
+
+	hashedPassword, err := cfg.passwords.Hash(emailReq.Password)
+	if errors.Is(err, auth.ErrPasswordBusy) {
+		writePasswordBusyResponse(rw)
+		return
+	}
 	if err != nil {
 		writeErrorResponse(rw, 403, "couldn't generate hashed password")
 		return
@@ -396,6 +747,7 @@ func (cfg *apiConfig) handlerCreateUser(rw http.ResponseWriter, r *http.Request)
 		HashedPassword: hashedPassword,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
+		Role:           string(auth.RoleUser),
 	})
 	if err != nil {
 		writeErrorResponse(rw, 401, "couldn't create the user")
@@ -406,6 +758,9 @@ func (cfg *apiConfig) handlerCreateUser(rw http.ResponseWriter, r *http.Request)
 		"id":            user.ID,
 		"email":         user.Email,
 		"is_chirpy_red": user.IsChirpyRed,
+		"role":          user.Role,
+		"avatar_urls":   cfg.avatarURLs(user),
+		"is_protected":  user.IsProtected,
 		"created_at":    user.CreatedAt,
 		"updated_at":    user.UpdatedAt,
 	})
@@ -436,14 +791,30 @@ func (cfg *apiConfig) handlerUpdateUser(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate JWT and get user UUID
-	userUUID, err := cfg.validateJWTFromRequest(r)
-	if err != nil {
+	// Validate JWT and get the user, a pending password reset is exactly what this endpoint resolves
+	user, err := cfg.authenticateUser(r)
+	if err != nil && !errors.Is(err, errPasswordResetRequired) {
 		writeErrorResponse(rw, 401, err.Error())
 		return
 	}
+	userUUID := user.ID
 
-	hashedPassword, err := auth.HashPassword(userUpdateReq.Password)
+	// Enforce the password policy
+	passwordErrors, err := cfg.validatePassword(userUpdateReq.Password)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't validate the password")
+		return
+	}
+	if len(passwordErrors) > 0 {
+		writeValidationErrorResponse(rw, passwordErrors)
+		return
+	}
+
+	hashedPassword, err := cfg.passwords.Hash(userUpdateReq.Password)
+	if errors.Is(err, auth.ErrPasswordBusy) {
+		writePasswordBusyResponse(rw)
+		return
+	}
 	if err != nil {
 		writeErrorResponse(rw, 403, "couldn't hash user's password")
 		return
@@ -463,6 +834,9 @@ func (cfg *apiConfig) handlerUpdateUser(rw http.ResponseWriter, r *http.Request)
 		"id":            updatedUser.ID,
 		"email":         updatedUser.Email,
 		"is_chirpy_red": updatedUser.IsChirpyRed,
+		"role":          updatedUser.Role,
+		"avatar_urls":   cfg.avatarURLs(updatedUser),
+		"is_protected":  updatedUser.IsProtected,
 		"created_at":    updatedUser.CreatedAt,
 		"updated_at":    updatedUser.UpdatedAt,
 	})
@@ -497,21 +871,73 @@ func (cfg *apiConfig) handlerLoginUser(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Get the user data for password verification
+	// Refuse early if this account or this client is backing off / locked out.
+	// Every allowed attempt is reserved, it's settled below by Fail, Release or Reset
+	accountKey := strings.ToLower(strings.TrimSpace(loginReq.Email))
+	clientIP := clientIPFromRequest(r)
+	accountWait, accountAllowed := cfg.accountThrottle.Allow(accountKey)
+	ipWait, ipAllowed := cfg.ipThrottle.Allow(clientIP)
+	if !accountAllowed || !ipAllowed {
+		if accountAllowed {
+			cfg.accountThrottle.Release(accountKey)
+		}
+		if ipAllowed {
+			cfg.ipThrottle.Release(clientIP)
+		}
+		retryAfter := max(accountWait, ipWait)
+		rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		writeErrorResponse(rw, 429, "too many failed login attempts, try again later")
+		return
+	}
+
+	// Unknown users and wrong passwords get the same answer after the same amount of work,
+	// so the login endpoint can't be used to find out which emails have an account
 	user, err := cfg.db.GetUserByEmail(r.Context(), loginReq.Email)
 	if err != nil {
-		writeErrorResponse(rw, 403, "user not found")
+		if busyErr := cfg.passwords.SimulateVerify(loginReq.Password); busyErr != nil {
+			err = busyErr
+		}
+	} else {
+		err = cfg.passwords.Verify(loginReq.Password, user.HashedPassword)
+	}
+	// not a failed attempt, the server is overloaded
+	if errors.Is(err, auth.ErrPasswordBusy) {
+		cfg.accountThrottle.Release(accountKey)
+		cfg.ipThrottle.Release(clientIP)
+		writePasswordBusyResponse(rw)
 		return
 	}
-
-	// Hash the User password
-	HashedPassByteSli := []byte(user.HashedPassword)
-	err = auth.ComparePasswordHash(loginReq.Password, string(HashedPassByteSli))
 	if err != nil {
-		writeErrorResponse(rw, 401, "password is not correct")
+		cfg.accountThrottle.Fail(accountKey)
+		cfg.ipThrottle.Fail(clientIP)
+		writeErrorResponse(rw, 401, "incorrect email or password")
+		return
+	}
+
+	// Suspended accounts can't log in, this is only told to whoever knows the password
+	if user.SuspendedAt.Valid {
+		cfg.accountThrottle.Release(accountKey)
+		cfg.ipThrottle.Release(clientIP)
+		writeErrorResponse(rw, 403, "account is suspended")
 		return
 	}
 
+	// only the account is cleared, a valid login must not wipe the failures of other accounts tried from the same IP
+	cfg.accountThrottle.Reset(accountKey)
+	cfg.ipThrottle.Release(clientIP)
+
+	// Upgrade hashes made with an older algorithm or outdated parameters, now that we know the plain password
+	if cfg.passwords.NeedsRehash(user.HashedPassword) {
+		if rehashedPassword, err := cfg.passwords.Hash(loginReq.Password); err != nil {
+			log.Printf("couldn't rehash the password of user %s: %v", user.ID, err)
+		} else if err := cfg.db.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			HashedPassword: rehashedPassword,
+			ID:             user.ID,
+		}); err != nil {
+			log.Printf("couldn't store the rehashed password of user %s: %v", user.ID, err)
+		}
+	}
+
 	// Password is Valid
 	// Generate JWT for the user
 	generatedToken, err := auth.MakeJWT(
@@ -543,10 +969,15 @@ func (cfg *apiConfig) handlerLoginUser(rw http.ResponseWriter, r *http.Request)
 		"id":            user.ID,
 		"email":         user.Email,
 		"is_chirpy_red": user.IsChirpyRed,
+		"role":          user.Role,
+		"avatar_urls":   cfg.avatarURLs(user),
+		"is_protected":  user.IsProtected,
 		"created_at":    user.CreatedAt,
 		"updated_at":    user.UpdatedAt,
 		"token":         generatedToken,
 		"refresh_token": refreshToken,
+		// when true, the tokens only allow PUT /api/users until the password is changed
+		"password_reset_required": user.PasswordResetRequired,
 	})
 }
 
@@ -565,13 +996,18 @@ func (cfg *apiConfig) handlerRefreshToken(rw http.ResponseWriter, r *http.Reques
 	}
 
 	// Refresh token is still valid => Create an Access Token for the user, as the current one is expired, that's why this RefreshToken api is called in the first place
-	user, err := cfg.db.GetUserFromRefreshToken(r.Context(), foundRefreshToken.UserID)
+	user, err := cfg.db.GetUserByID(r.Context(), foundRefreshToken.UserID)
 	if err != nil {
 		writeErrorResponse(rw, 404, "user not found")
 		return
 	}
 
-	JWT, err := auth.MakeJWT(user.UserID, cfg.jwtSecret, time.Hour*1)
+	if user.SuspendedAt.Valid {
+		writeErrorResponse(rw, 401, "account is suspended")
+		return
+	}
+
+	JWT, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour*1)
 	if err != nil {
 		writeErrorResponse(rw, 401, "couldn't create Access Token for the user")
 		return
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/auth"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+)
+
+// adminUserResponse is the admin view of a user, it includes the moderation related fields
+func adminUserResponse(user database.User) map[string]any {
+	var suspendedAt *time.Time
+	if user.SuspendedAt.Valid {
+		suspendedAt = &user.SuspendedAt.Time
+	}
+
+	return map[string]any{
+		"id":                      user.ID,
+		"email":                   user.Email,
+		"is_chirpy_red":           user.IsChirpyRed,
+		"role":                    user.Role,
+		"suspended_at":            suspendedAt,
+		"suspension_reason":       user.SuspensionReason,
+		"chirps_hidden":           user.ChirpsHidden,
+		"password_reset_required": user.PasswordResetRequired,
+		"created_at":              user.CreatedAt,
+		"updated_at":              user.UpdatedAt,
+	}
+}
+
+// adminTargetUser loads the user from the {userID} path value, writing the error response itself
+func (cfg *apiConfig) adminTargetUser(rw http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userUUID, err := validateUUID(r.PathValue("userID"), "user ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "user not found")
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+// escapeLikePattern escapes the LIKE wildcards, so "_" and "%" in a search match themselves
+func escapeLikePattern(search string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(search)
+}
+
+func (cfg *apiConfig) handlerAdminListUsers(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 20, 100)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	// [Optional] filters => q (email search), role, suspended
+	urlValues := r.URL.Query()
+	filters := database.CountUsersParams{}
+
+	if search := strings.TrimSpace(urlValues.Get("q")); search != "" {
+		filters.Search = sql.NullString{String: escapeLikePattern(search), Valid: true}
+	}
+
+	if roleStr := urlValues.Get("role"); roleStr != "" {
+		role, err := auth.ParseRole(roleStr)
+		if err != nil {
+			writeErrorResponse(rw, 400, err.Error())
+			return
+		}
+		filters.Role = sql.NullString{String: string(role), Valid: true}
+	}
+
+	switch urlValues.Get("suspended") {
+	case "":
+	case "true":
+		filters.Suspended = sql.NullBool{Bool: true, Valid: true}
+	case "false":
+		filters.Suspended = sql.NullBool{Bool: false, Valid: true}
+	default:
+		writeErrorResponse(rw, 400, "suspended must be true or false")
+		return
+	}
+
+	users, err := cfg.db.ListUsers(r.Context(), database.ListUsersParams{
+		Search:    filters.Search,
+		Role:      filters.Role,
+		Suspended: filters.Suspended,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch users")
+		return
+	}
+
+	total, err := cfg.db.CountUsers(r.Context(), filters)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count users")
+		return
+	}
+
+	usersResponseJson := make([]map[string]any, len(users))
+	for i, user := range users {
+		usersResponseJson[i] = adminUserResponse(user)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"users":  usersResponseJson,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (cfg *apiConfig) handlerAdminGetUser(rw http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(rw, r)
+	if !ok {
+		return
+	}
+
+	writeSuccessResponse(rw, 200, adminUserResponse(user))
+}
+
+func (cfg *apiConfig) handlerAdminSuspendUser(rw http.ResponseWriter, r *http.Request) {
+	type SuspendRequest struct {
+		Reason string `json:"reason"`
+		// hide the user's chirps from the public listings while suspended
+		HideChirps bool `json:"hide_chirps"`
+	}
+
+	var suspendReq SuspendRequest
+
+	// the body is optional, an empty one suspends without a reason
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&suspendReq)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	user, ok := cfg.adminTargetUser(rw, r)
+	if !ok {
+		return
+	}
+
+	admin, _ := userFromContext(r.Context())
+	if admin.ID == user.ID {
+		writeErrorResponse(rw, 403, "admins can't suspend themselves")
+		return
+	}
+
+	suspendedUser, err := suspendUser(r.Context(), cfg.db, user, suspendReq.Reason, suspendReq.HideChirps)
+	if err != nil {
+		writeErrorResponse(rw, 500, err.Error())
+		return
+	}
+
+	writeSuccessResponse(rw, 200, adminUserResponse(suspendedUser))
+}
+
+// suspendUser suspends the user and revokes their refresh tokens, so they are logged out everywhere.
+// It takes the queries to run on, so it can be part of a bigger transaction.
+func suspendUser(ctx context.Context, q *database.Queries, user database.User, reason string, hideChirps bool) (database.User, error) {
+	suspendedUser, err := q.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedAt:      sql.NullTime{Time: time.Now(), Valid: true},
+		SuspensionReason: strings.TrimSpace(reason),
+		ChirpsHidden:     hideChirps,
+		UpdatedAt:        time.Now(),
+		ID:               user.ID,
+	})
+	if err != nil {
+		return database.User{}, errors.New("couldn't suspend the user")
+	}
+
+	err = q.RevokeUserRefreshTokens(ctx, database.RevokeUserRefreshTokensParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		return database.User{}, errors.New("couldn't revoke the user's refresh tokens")
+	}
+
+	return suspendedUser, nil
+}
+
+func (cfg *apiConfig) handlerAdminUnsuspendUser(rw http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(rw, r)
+	if !ok {
+		return
+	}
+
+	unsuspendedUser, err := cfg.db.UnsuspendUser(r.Context(), database.UnsuspendUserParams{
+		UpdatedAt: time.Now(),
+		ID:        user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unsuspend the user")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, adminUserResponse(unsuspendedUser))
+}
+
+// handlerAdminForcePasswordReset logs the user out everywhere, their next login only allows changing the password
+func (cfg *apiConfig) handlerAdminForcePasswordReset(rw http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(rw, r)
+	if !ok {
+		return
+	}
+
+	updatedUser, err := cfg.db.RequirePasswordReset(r.Context(), database.RequirePasswordResetParams{
+		UpdatedAt: time.Now(),
+		ID:        user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't flag the user for a password reset")
+		return
+	}
+
+	err = cfg.db.RevokeUserRefreshTokens(r.Context(), database.RevokeUserRefreshTokensParams{
+		RevokedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: time.Now(),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't revoke the user's refresh tokens")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, adminUserResponse(updatedUser))
+}
+
+func (cfg *apiConfig) handlerAdminSetUserRole(rw http.ResponseWriter, r *http.Request) {
+	type RoleRequest struct {
+		Role string `json:"role"`
+	}
+
+	var roleReq RoleRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&roleReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	role, err := auth.ParseRole(roleReq.Role)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	user, ok := cfg.adminTargetUser(rw, r)
+	if !ok {
+		return
+	}
+
+	// an admin demoting themselves could leave nobody able to manage roles
+	admin, _ := userFromContext(r.Context())
+	if admin.ID == user.ID {
+		writeErrorResponse(rw, 403, "admins can't change their own role")
+		return
+	}
+
+	updatedUser, err := cfg.db.SetUserRole(r.Context(), database.SetUserRoleParams{
+		Role:      string(role),
+		UpdatedAt: time.Now(),
+		ID:        user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the user's role")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, adminUserResponse(updatedUser))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/media"
+	"github.com/google/uuid"
+)
+
+// avatarSizes are the square sizes (in pixels) every avatar is stored in
+var avatarSizes = []int{48, 128, 400}
+
+func avatarKey(avatarID uuid.UUID, size int) string {
+	return fmt.Sprintf("avatars/%s/%d", avatarID, size)
+}
+
+// avatarURLs maps every avatar size to its URL, nil when the user has no avatar
+func (cfg *apiConfig) avatarURLs(user database.User) map[string]string {
+	if !user.AvatarID.Valid {
+		return nil
+	}
+
+	urls := map[string]string{}
+	for _, size := range avatarSizes {
+		urls[strconv.Itoa(size)] = fmt.Sprintf("%s/api/avatars/%s/%d", cfg.mediaBaseURL, user.AvatarID.UUID, size)
+	}
+	return urls
+}
+
+// deleteAvatarBlobs removes every size of a replaced or removed avatar, failures only leave orphaned files
+func (cfg *apiConfig) deleteAvatarBlobs(ctx context.Context, avatarID uuid.NullUUID) {
+	if !avatarID.Valid {
+		return
+	}
+
+	for _, size := range avatarSizes {
+		key := avatarKey(avatarID.UUID, size)
+		if err := cfg.blobs.Delete(ctx, key); err != nil {
+			log.Printf("couldn't delete the avatar blob %s: %v", key, err)
+		}
+	}
+}
+
+// handlerSetAvatar takes the image as a multipart "file" field, or as the raw request body
+func (cfg *apiConfig) handlerSetAvatar(rw http.ResponseWriter, r *http.Request) {
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, cfg.avatarMaxBytes+multipartOverhead)
+	defer r.Body.Close()
+
+	var data []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		_, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			writeErrorResponse(rw, 400, "missing the image in the file field, or it's too large")
+			return
+		}
+
+		data, err = readUpload(fileHeader, cfg.avatarMaxBytes)
+		if err != nil {
+			writeErrorResponse(rw, 400, err.Error())
+			return
+		}
+	} else {
+		data, err = io.ReadAll(io.LimitReader(r.Body, cfg.avatarMaxBytes+1))
+		if err != nil {
+			writeErrorResponse(rw, 400, "couldn't read the image")
+			return
+		}
+		if int64(len(data)) > cfg.avatarMaxBytes {
+			writeErrorResponse(rw, 400, fmt.Sprintf("image is too large, the limit is %d bytes", cfg.avatarMaxBytes))
+			return
+		}
+	}
+
+	avatar, err := media.ProcessAvatar(data, media.Options{MaxPixels: cfg.mediaMaxPixels}, avatarSizes)
+	if err != nil {
+		writeStoreMediaError(rw, err)
+		return
+	}
+
+	avatarID := uuid.New()
+	for size, image := range avatar.Sizes {
+		if err := cfg.blobs.Put(r.Context(), avatarKey(avatarID, size), avatar.ContentType, image); err != nil {
+			writeStoreMediaError(rw, err)
+			return
+		}
+	}
+
+	updatedUser, err := cfg.db.SetUserAvatar(r.Context(), database.SetUserAvatarParams{
+		AvatarID:          uuid.NullUUID{UUID: avatarID, Valid: true},
+		AvatarContentType: avatar.ContentType,
+		AvatarUpdatedAt:   sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt:         time.Now(),
+		ID:                user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the avatar")
+		return
+	}
+
+	cfg.deleteAvatarBlobs(r.Context(), user.AvatarID)
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"id":          updatedUser.ID,
+		"avatar_urls": cfg.avatarURLs(updatedUser),
+		"updated_at":  updatedUser.UpdatedAt,
+	})
+}
+
+func (cfg *apiConfig) handlerDeleteAvatar(rw http.ResponseWriter, r *http.Request) {
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	_, err = cfg.db.SetUserAvatar(r.Context(), database.SetUserAvatarParams{
+		AvatarID:          uuid.NullUUID{},
+		AvatarContentType: "",
+		AvatarUpdatedAt:   sql.NullTime{},
+		UpdatedAt:         time.Now(),
+		ID:                user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't remove the avatar")
+		return
+	}
+
+	cfg.deleteAvatarBlobs(r.Context(), user.AvatarID)
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerGetAvatar(rw http.ResponseWriter, r *http.Request) {
+	avatarUUID, err := validateUUID(r.PathValue("avatarID"), "avatar ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	size, err := strconv.Atoi(r.PathValue("size"))
+	if err != nil || !slices.Contains(avatarSizes, size) {
+		writeErrorResponse(rw, 404, "avatar size not found")
+		return
+	}
+
+	// only the current avatar of a user is served, replaced ones are gone
+	user, err := cfg.db.GetUserByAvatarID(r.Context(), uuid.NullUUID{UUID: avatarUUID, Valid: true})
+	if err != nil {
+		writeErrorResponse(rw, 404, "avatar not found")
+		return
+	}
+
+	cfg.serveBlob(rw, r, avatarKey(avatarUUID, size), user.AvatarContentType, user.AvatarUpdatedAt.Time, true)
+}
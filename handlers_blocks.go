@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// A block hides the blocker's chirps from the blocked user and stops them from interacting with the blocker,
+// a mute only hides the muted user's chirps from the muter's feed. Neither is ever shown to the other user.
+
+// isBlocked reports whether blockerID blocked blockedID
+func (cfg *apiConfig) isBlocked(ctx context.Context, blockerID, blockedID uuid.UUID) (bool, error) {
+	return cfg.db.IsBlocked(ctx, database.IsBlockedParams{
+		BlockerID: blockerID,
+		BlockedID: blockedID,
+	})
+}
+
+func userRelationResponse(userID uuid.UUID, createdAt time.Time) map[string]any {
+	return map[string]any{
+		"user_id":    userID,
+		"created_at": createdAt,
+	}
+}
+
+// relationTarget reads the {userID} of a block / mute / follow request, writing the error response itself
+func (cfg *apiConfig) relationTarget(rw http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	targetUUID, err := validateUUID(r.PathValue("userID"), "user ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetUUID == userUUID {
+		writeErrorResponse(rw, 400, "you can't block, mute or follow yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userUUID, targetUUID, true
+}
+
+// ensureUserExists writes a 404 when the user doesn't exist
+func (cfg *apiConfig) ensureUserExists(rw http.ResponseWriter, r *http.Request, userUUID uuid.UUID) bool {
+	_, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 404, "user not found")
+		return false
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the user")
+		return false
+	}
+
+	return true
+}
+
+func (cfg *apiConfig) handlerBlockUser(rw http.ResponseWriter, r *http.Request) {
+	userUUID, targetUUID, ok := cfg.relationTarget(rw, r)
+	if !ok || !cfg.ensureUserExists(rw, r, targetUUID) {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't block the user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// blocking twice keeps the first block
+	err = qtx.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userUUID,
+		BlockedID: targetUUID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't block the user")
+		return
+	}
+
+	// a block ends the follows (and pending requests) between the two users
+	err = qtx.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		UserA: userUUID,
+		UserB: targetUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't block the user")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(rw, 500, "couldn't block the user")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(rw http.ResponseWriter, r *http.Request) {
+	userUUID, targetUUID, ok := cfg.relationTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	unblocked, err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userUUID,
+		BlockedID: targetUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unblock the user")
+		return
+	}
+	if unblocked == 0 {
+		writeErrorResponse(rw, 404, "this user isn't blocked")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerListBlockedUsers(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	blocks, err := cfg.db.ListBlockedUsers(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the blocked users")
+		return
+	}
+
+	blocksResponseJson := make([]map[string]any, len(blocks))
+	for i, block := range blocks {
+		blocksResponseJson[i] = userRelationResponse(block.BlockedID, block.CreatedAt)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"blocked_users": blocksResponseJson,
+	})
+}
+
+func (cfg *apiConfig) handlerMuteUser(rw http.ResponseWriter, r *http.Request) {
+	userUUID, targetUUID, ok := cfg.relationTarget(rw, r)
+	if !ok || !cfg.ensureUserExists(rw, r, targetUUID) {
+		return
+	}
+
+	err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID:   userUUID,
+		MutedID:   targetUUID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't mute the user")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(rw http.ResponseWriter, r *http.Request) {
+	userUUID, targetUUID, ok := cfg.relationTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	unmuted, err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userUUID,
+		MutedID: targetUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unmute the user")
+		return
+	}
+	if unmuted == 0 {
+		writeErrorResponse(rw, 404, "this user isn't muted")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerListMutedUsers(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	mutes, err := cfg.db.ListMutedUsers(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the muted users")
+		return
+	}
+
+	mutesResponseJson := make([]map[string]any, len(mutes))
+	for i, mute := range mutes {
+		mutesResponseJson[i] = userRelationResponse(mute.MutedID, mute.CreatedAt)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"muted_users": mutesResponseJson,
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// Bookmarks are private, only their owner can see them and no chirp response counts them
+
+func (cfg *apiConfig) handlerBookmarkChirp(rw http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// only chirps the user can see can be bookmarked
+	chirp, err := cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpUUID,
+		ViewerID: uuid.NullUUID{UUID: userUUID, Valid: true},
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "Chirp not found")
+		return
+	}
+
+	// bookmarking twice keeps the first bookmark
+	err = cfg.db.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:    userUUID,
+		ChirpID:   chirp.ID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't bookmark the chirp")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerRemoveBookmark(rw http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	removed, err := cfg.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userUUID,
+		ChirpID: chirpUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't remove the bookmark")
+		return
+	}
+	if removed == 0 {
+		writeErrorResponse(rw, 404, "bookmark not found")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+// handlerListBookmarks lists the user's bookmarked chirps, most recently bookmarked first.
+// Chirps the user can no longer see (deleted, hidden, author blocked them or stopped being followed) are left out.
+func (cfg *apiConfig) handlerListBookmarks(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 20, 100)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.ListBookmarkedChirps(r.Context(), database.ListBookmarkedChirpsParams{
+		UserID: userUUID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the bookmarks")
+		return
+	}
+
+	total, err := cfg.db.CountBookmarkedChirps(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the bookmarks")
+		return
+	}
+
+	// like the feed, the muted chirps are dropped from the page, the total still counts them
+	chirps, err = cfg.withoutMutedWords(r.Context(), userUUID, chirps)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't apply the muted words")
+		return
+	}
+
+	attachments, err := cfg.chirpsAttachments(r.Context(), chirps)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirps' media")
+		return
+	}
+
+	chirpsResponseJson := make([]map[string]any, len(chirps))
+	for i, chirp := range chirps {
+		chirpsResponseJson[i] = cfg.chirpResponse(chirp, attachments[chirp.ID])
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"chirps": chirpsResponseJson,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
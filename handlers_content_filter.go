@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/contentfilter"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func contentFilterRuleResponse(rule database.ContentFilterRule) map[string]any {
+	return map[string]any{
+		"id":         rule.ID,
+		"pattern":    rule.Pattern,
+		"action":     rule.Action,
+		"created_at": rule.CreatedAt,
+		"updated_at": rule.UpdatedAt,
+	}
+}
+
+type contentFilterRuleRequest struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+// decodeContentFilterRule reads & validates the rule from the request body, writing the error response itself
+func decodeContentFilterRule(rw http.ResponseWriter, r *http.Request) (contentFilterRuleRequest, bool) {
+	var ruleReq contentFilterRuleRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&ruleReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return ruleReq, false
+	}
+	defer r.Body.Close()
+
+	ruleReq.Pattern = strings.TrimSpace(ruleReq.Pattern)
+	if !contentfilter.ValidPattern(ruleReq.Pattern) {
+		writeErrorResponse(rw, 400, "pattern must contain at least one word")
+		return ruleReq, false
+	}
+
+	if _, err := contentfilter.ParseAction(ruleReq.Action); err != nil {
+		writeErrorResponse(rw, 400, "action must be one of: mask, reject, flag")
+		return ruleReq, false
+	}
+
+	return ruleReq, true
+}
+
+// reloadContentFilter applies a rule change right away, the periodic reload retries if it fails
+func (cfg *apiConfig) reloadContentFilter(r *http.Request) {
+	if err := cfg.contentFilter.Reload(r.Context()); err != nil {
+		log.Printf("couldn't reload the content filter: %v", err)
+	}
+}
+
+func (cfg *apiConfig) handlerAdminListContentFilterRules(rw http.ResponseWriter, r *http.Request) {
+	rules, err := cfg.db.ListContentFilterRules(r.Context())
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the content filter rules")
+		return
+	}
+
+	rulesResponseJson := make([]map[string]any, len(rules))
+	for i, rule := range rules {
+		rulesResponseJson[i] = contentFilterRuleResponse(rule)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"rules": rulesResponseJson,
+		// includes the rules of CONTENT_FILTER_FILE, if any
+		"active_rules": len(cfg.contentFilter.Rules()),
+	})
+}
+
+func (cfg *apiConfig) handlerAdminCreateContentFilterRule(rw http.ResponseWriter, r *http.Request) {
+	ruleReq, ok := decodeContentFilterRule(rw, r)
+	if !ok {
+		return
+	}
+
+	rule, err := cfg.db.CreateContentFilterRule(r.Context(), database.CreateContentFilterRuleParams{
+		ID:        uuid.New(),
+		Pattern:   ruleReq.Pattern,
+		Action:    ruleReq.Action,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			writeErrorResponse(rw, 409, "a rule with this pattern already exists")
+			return
+		}
+		writeErrorResponse(rw, 500, "couldn't create the content filter rule")
+		return
+	}
+
+	cfg.reloadContentFilter(r)
+
+	writeSuccessResponse(rw, 201, contentFilterRuleResponse(rule))
+}
+
+func (cfg *apiConfig) handlerAdminUpdateContentFilterRule(rw http.ResponseWriter, r *http.Request) {
+	ruleUUID, err := validateUUID(r.PathValue("ruleID"), "rule ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	ruleReq, ok := decodeContentFilterRule(rw, r)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.db.GetContentFilterRule(r.Context(), ruleUUID); err != nil {
+		writeErrorResponse(rw, 404, "rule not found")
+		return
+	}
+
+	rule, err := cfg.db.UpdateContentFilterRule(r.Context(), database.UpdateContentFilterRuleParams{
+		Pattern:   ruleReq.Pattern,
+		Action:    ruleReq.Action,
+		UpdatedAt: time.Now(),
+		ID:        ruleUUID,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			writeErrorResponse(rw, 409, "a rule with this pattern already exists")
+			return
+		}
+		writeErrorResponse(rw, 500, "couldn't update the content filter rule")
+		return
+	}
+
+	cfg.reloadContentFilter(r)
+
+	writeSuccessResponse(rw, 200, contentFilterRuleResponse(rule))
+}
+
+func (cfg *apiConfig) handlerAdminDeleteContentFilterRule(rw http.ResponseWriter, r *http.Request) {
+	ruleUUID, err := validateUUID(r.PathValue("ruleID"), "rule ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	deleted, err := cfg.db.DeleteContentFilterRule(r.Context(), ruleUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't delete the content filter rule")
+		return
+	}
+	if deleted == 0 {
+		writeErrorResponse(rw, 404, "rule not found")
+		return
+	}
+
+	cfg.reloadContentFilter(r)
+
+	writeEmptyResponse(rw, 204)
+}
+
+// handlerAdminReloadContentFilter picks up rules changed directly in the DB or in CONTENT_FILTER_FILE
+func (cfg *apiConfig) handlerAdminReloadContentFilter(rw http.ResponseWriter, r *http.Request) {
+	if err := cfg.contentFilter.Reload(r.Context()); err != nil {
+		writeErrorResponse(rw, 500, err.Error())
+		return
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"active_rules": len(cfg.contentFilter.Rules()),
+	})
+}
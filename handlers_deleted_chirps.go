@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// purgeBatchSize is the number of soft-deleted chirps a replica locks & purges per transaction
+const purgeBatchSize = 100
+
+// handlerListDeletedChirps lists the chirps the user deleted that can still be restored
+func (cfg *apiConfig) handlerListDeletedChirps(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.ListDeletedChirps(r.Context(), database.ListDeletedChirpsParams{
+		UserID:       userUUID,
+		DeletedAfter: time.Now().Add(-cfg.chirpRestoreWindow),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the deleted chirps")
+		return
+	}
+
+	attachments, err := cfg.chirpsAttachments(r.Context(), chirps)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirps' media")
+		return
+	}
+
+	chirpsResponseJson := make([]map[string]any, len(chirps))
+	for i, chirp := range chirps {
+		chirpsResponseJson[i] = cfg.chirpResponse(chirp, attachments[chirp.ID])
+		chirpsResponseJson[i]["deleted_at"] = chirp.DeletedAt.Time
+		chirpsResponseJson[i]["restorable_until"] = chirp.DeletedAt.Time.Add(cfg.chirpRestoreWindow)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"chirps": chirpsResponseJson,
+	})
+}
+
+// handlerRestoreChirp undoes a delete made by the author during the grace period,
+// chirps deleted by a moderator can't be restored
+func (cfg *apiConfig) handlerRestoreChirp(rw http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	restored, err := cfg.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		UpdatedAt:    time.Now(),
+		ID:           chirpUUID,
+		UserID:       userUUID,
+		DeletedAfter: time.Now().Add(-cfg.chirpRestoreWindow),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't restore the chirp")
+		return
+	}
+	if restored == 0 {
+		writeErrorResponse(rw, 404, "no deleted chirp to restore, or its grace period is over")
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpy(r.Context(), chirpUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the restored chirp")
+		return
+	}
+
+	attachments, err := cfg.db.GetMediaAttachmentsByChirpIDs(r.Context(), []uuid.UUID{chirp.ID})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirp's media")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, cfg.chirpResponse(chirp, attachments))
+}
+
+// purgeDeletedChirps removes the chirps soft-deleted for longer than the retention, along with their media files.
+// Batches are locked with FOR UPDATE SKIP LOCKED, so several replicas can run it at the same time.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) (int, error) {
+	purged := 0
+	for {
+		count, err := cfg.purgeDeletedChirpsBatch(ctx)
+		if err != nil {
+			return purged, err
+		}
+
+		purged += count
+		if count < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeDeletedChirpsBatch(ctx context.Context) (int, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirpIDs, err := qtx.GetPurgeableChirpIDs(ctx, database.GetPurgeableChirpIDsParams{
+		DeletedBefore: time.Now().Add(-cfg.chirpRetention),
+		BatchSize:     purgeBatchSize,
+	})
+	if err != nil || len(chirpIDs) == 0 {
+		return 0, err
+	}
+
+	// the attachment rows go with the chirps, their files are removed once that's committed
+	attachments, err := qtx.GetMediaAttachmentsByChirpIDs(ctx, chirpIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := qtx.DeleteChirpsByIDs(ctx, chirpIDs); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	cfg.deleteMediaBlobs(ctx, attachments)
+
+	return len(chirpIDs), nil
+}
+
+// runChirpPurger runs purgeDeletedChirps every interval until the context is cancelled
+func (cfg *apiConfig) runChirpPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.purgeDeletedChirps(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("couldn't purge the deleted chirps: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("purged %d deleted chirps", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/auth"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/mailer"
+)
+
+const (
+	digestOff    = "off"
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+
+	// digestBatchSize is the number of digests a replica claims at once
+	digestBatchSize = 50
+	// digestTopChirps is the number of chirps from the followed accounts a digest lists
+	digestTopChirps = 5
+	// digestSendTimeout bounds the delivery of one digest
+	digestSendTimeout = time.Second * 30
+	// digestLease is how long a claimed digest is left to its replica, a failed one is retried once it's over.
+	// It outlasts a batch whose every delivery times out, so no digest is claimed again while it's sent
+	digestLease = digestBatchSize*digestSendTimeout + time.Minute*5
+	// digestConfirmationCooldown is the time before another confirmation email can be sent to a user
+	digestConfirmationCooldown = time.Minute * 10
+)
+
+// digestPeriod is the time a digest covers, and the time until the next one
+func digestPeriod(frequency string) time.Duration {
+	if frequency == digestWeekly {
+		return time.Hour * 24 * 7
+	}
+	return time.Hour * 24
+}
+
+func digestSettingsResponse(user database.User) map[string]any {
+	var sentAt *time.Time
+	if user.DigestSentAt.Valid {
+		sentAt = &user.DigestSentAt.Time
+	}
+
+	// waiting for the confirmation of the address
+	var pendingFrequency *string
+	if user.DigestPendingFrequency.Valid {
+		pendingFrequency = &user.DigestPendingFrequency.String
+	}
+
+	return map[string]any{
+		"frequency":         user.DigestFrequency,
+		"pending_frequency": pendingFrequency,
+		"last_sent_at":      sentAt,
+	}
+}
+
+func (cfg *apiConfig) handlerGetDigestSettings(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "user not found")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, digestSettingsResponse(user))
+}
+
+// handlerSetDigestSettings sets how often the user gets the activity digest => off | daily | weekly.
+// The digest is double opt-in: until the user confirms their address from the link sent there, the frequency
+// stays pending (202), so nobody can point recurring mail at an address that isn't theirs.
+func (cfg *apiConfig) handlerSetDigestSettings(rw http.ResponseWriter, r *http.Request) {
+	type DigestReq struct {
+		Frequency string `json:"frequency"`
+	}
+
+	var digestReq DigestReq
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&digestReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	switch digestReq.Frequency {
+	case digestOff, digestDaily, digestWeekly:
+	default:
+		writeErrorResponse(rw, 400, "frequency must be off, daily or weekly")
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "user not found")
+		return
+	}
+
+	// only used when the user has no token yet
+	unsubscribeToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the digest settings")
+		return
+	}
+
+	confirmed := user.DigestConfirmedEmail.Valid && user.DigestConfirmedEmail.String == user.Email
+	if digestReq.Frequency == digestOff || confirmed {
+		user, err = cfg.db.SetDigestFrequency(r.Context(), database.SetDigestFrequencyParams{
+			DigestFrequency:  digestReq.Frequency,
+			UnsubscribeToken: unsubscribeToken,
+			UpdatedAt:        time.Now(),
+			ID:               userUUID,
+		})
+		if err != nil {
+			writeErrorResponse(rw, 500, "couldn't update the digest settings")
+			return
+		}
+
+		writeSuccessResponse(rw, 200, digestSettingsResponse(user))
+		return
+	}
+
+	confirmationToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the digest settings")
+		return
+	}
+
+	now := time.Now()
+	user, err = cfg.db.RequestDigestConfirmation(r.Context(), database.RequestDigestConfirmationParams{
+		PendingFrequency:  digestReq.Frequency,
+		ConfirmationToken: confirmationToken,
+		UpdatedAt:         now,
+		UnsubscribeToken:  unsubscribeToken,
+		ID:                userUUID,
+		ResendBefore:      now.Add(-digestConfirmationCooldown),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 429, "a confirmation email was sent recently, check your inbox")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the digest settings")
+		return
+	}
+
+	confirmURL := fmt.Sprintf("%s/api/digest/confirm?token=%s", cfg.appBaseURL, url.QueryEscape(confirmationToken))
+	sendCtx, cancel := context.WithTimeout(r.Context(), digestSendTimeout)
+	defer cancel()
+	err = cfg.mailer.Send(sendCtx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your Chirpy digest",
+		Text: fmt.Sprintf("Confirm that you want the %s Chirpy digest at this address:\n\n%s\n\n"+
+			"If you didn't ask for it, ignore this email, nothing will be sent.\n", digestReq.Frequency, confirmURL),
+	})
+	if err != nil {
+		log.Printf("couldn't send the digest confirmation of user %s: %v", user.ID, err)
+		writeErrorResponse(rw, 500, "couldn't send the confirmation email")
+		return
+	}
+
+	writeSuccessResponse(rw, 202, digestSettingsResponse(user))
+}
+
+// handlerConfirmDigestPage answers the confirmation link of the digest, the form then POSTs to handlerConfirmDigest
+func (cfg *apiConfig) handlerConfirmDigestPage(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeErrorResponse(rw, 400, "missing required field: token")
+		return
+	}
+
+	_, err := cfg.db.GetUserByDigestConfirmationToken(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 404, "invalid confirmation link")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't load the confirmation link")
+		return
+	}
+
+	writeConfirmationPage(rw, "Get the Chirpy digest at this address?", "/api/digest/confirm", token, "Confirm")
+}
+
+// handlerConfirmDigest confirms the address of the user and applies the pending frequency
+func (cfg *apiConfig) handlerConfirmDigest(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeErrorResponse(rw, 400, "missing required field: token")
+		return
+	}
+
+	user, err := cfg.db.ConfirmDigest(r.Context(), database.ConfirmDigestParams{
+		UpdatedAt: time.Now(),
+		Token:     token,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 404, "invalid confirmation link")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't confirm the digest")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"message": fmt.Sprintf("you'll receive the %s activity digest", user.DigestFrequency),
+	})
+}
+
+// writeConfirmationPage writes the page of a link sent by email. Opening the link changes nothing, link scanners &
+// prefetchers follow the GET links of the emails on their own: the form POSTs the token to action.
+func writeConfirmationPage(rw http.ResponseWriter, title, action, token, button string) {
+	page := fmt.Sprintf(`<html>
+  <body>
+    <h1>%s</h1>
+    <form method="POST" action="%s?token=%s">
+      <button type="submit">%s</button>
+    </form>
+  </body>
+</html>`, html.EscapeString(title), action, html.EscapeString(url.QueryEscape(token)), html.EscapeString(button))
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(200)
+	if _, err := rw.Write([]byte(page)); err != nil {
+		log.Println("couldn't write the confirmation page:", err)
+	}
+}
+
+// handlerConfirmUnsubscribeDigest answers the unsubscribe link of a digest opened in a browser,
+// the form then POSTs to handlerUnsubscribeDigest
+func (cfg *apiConfig) handlerConfirmUnsubscribeDigest(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeErrorResponse(rw, 400, "missing required field: token")
+		return
+	}
+
+	_, err := cfg.db.GetUserByDigestUnsubscribeToken(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 404, "invalid unsubscribe link")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't load the unsubscribe link")
+		return
+	}
+
+	writeConfirmationPage(rw, "Unsubscribe from the Chirpy digest?", "/api/digest/unsubscribe", token, "Unsubscribe")
+}
+
+// handlerUnsubscribeDigest turns the digest off, the token of the email's link stands in for the login.
+// It's the POST of the confirmation page, and of the one-click unsubscribe of the mail clients (RFC 8058).
+func (cfg *apiConfig) handlerUnsubscribeDigest(rw http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeErrorResponse(rw, 400, "missing required field: token")
+		return
+	}
+
+	unsubscribed, err := cfg.db.UnsubscribeFromDigest(r.Context(), database.UnsubscribeFromDigestParams{
+		UpdatedAt: time.Now(),
+		Token:     token,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unsubscribe")
+		return
+	}
+	if unsubscribed == 0 {
+		writeErrorResponse(rw, 404, "invalid unsubscribe link")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"message": "you won't receive the activity digest anymore",
+	})
+}
+
+// buildDigest writes the digest of the period before now, ok is false when there's nothing to tell
+func (cfg *apiConfig) buildDigest(ctx context.Context, user database.User, now time.Time) (mailer.Message, bool, error) {
+	since := now.Add(-digestPeriod(user.DigestFrequency))
+
+	newFollowers, err := cfg.db.CountNewFollowersSince(ctx, database.CountNewFollowersSinceParams{
+		FolloweeID: user.ID,
+		Since:      since,
+	})
+	if err != nil {
+		return mailer.Message{}, false, err
+	}
+
+	topChirps, err := cfg.db.ListTopFollowedChirpsSince(ctx, database.ListTopFollowedChirpsSinceParams{
+		UserID: user.ID,
+		Since:  since,
+		Limit:  digestTopChirps,
+	})
+	if err != nil {
+		return mailer.Message{}, false, err
+	}
+	topChirps, err = cfg.withoutMutedWords(ctx, user.ID, topChirps)
+	if err != nil {
+		return mailer.Message{}, false, err
+	}
+
+	unreadNotifications, err := cfg.db.CountUnreadNotifications(ctx, user.ID)
+	if err != nil {
+		return mailer.Message{}, false, err
+	}
+
+	if newFollowers == 0 && len(topChirps) == 0 && unreadNotifications == 0 {
+		return mailer.Message{}, false, nil
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Here's your %s Chirpy digest.\n\n", user.DigestFrequency)
+	if newFollowers > 0 {
+		fmt.Fprintf(&text, "New followers: %d\n", newFollowers)
+	}
+	if unreadNotifications > 0 {
+		fmt.Fprintf(&text, "Unread notifications: %d\n", unreadNotifications)
+	}
+	if len(topChirps) > 0 {
+		text.WriteString("\nTop chirps from the accounts you follow:\n")
+		for _, chirp := range topChirps {
+			fmt.Fprintf(&text, "\n- %s\n  %s/api/chirps/%s\n", chirp.Body, cfg.appBaseURL, chirp.ID)
+		}
+	}
+
+	unsubscribeURL := fmt.Sprintf("%s/api/digest/unsubscribe?token=%s", cfg.appBaseURL, url.QueryEscape(user.DigestUnsubscribeToken.String))
+	fmt.Fprintf(&text, "\nUnsubscribe: %s\n", unsubscribeURL)
+
+	return mailer.Message{
+		To:             user.Email,
+		Subject:        fmt.Sprintf("Your %s Chirpy digest", user.DigestFrequency),
+		Text:           text.String(),
+		UnsubscribeURL: unsubscribeURL,
+	}, true, nil
+}
+
+// sendDueDigests sends the digests that are due.
+// A digest is leased when it's claimed and marked as sent once delivered, one that fails to send is retried
+// when its lease is over.
+func (cfg *apiConfig) sendDueDigests(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		now := time.Now()
+		users, err := cfg.db.ClaimDueDigests(ctx, database.ClaimDueDigestsParams{
+			LeaseUntil:   now.Add(digestLease),
+			Now:          now,
+			DailyBefore:  now.Add(-digestPeriod(digestDaily)),
+			WeeklyBefore: now.Add(-digestPeriod(digestWeekly)),
+			BatchSize:    digestBatchSize,
+		})
+		if err != nil {
+			return sent, err
+		}
+
+		for _, user := range users {
+			message, ok, err := cfg.buildDigest(ctx, user, now)
+			if err != nil {
+				log.Printf("couldn't build the digest of user %s: %v", user.ID, err)
+				continue
+			}
+			if ok {
+				// a stuck SMTP server would hold the whole batch
+				sendCtx, cancel := context.WithTimeout(ctx, digestSendTimeout)
+				err = cfg.mailer.Send(sendCtx, message)
+				cancel()
+				if err != nil {
+					log.Printf("couldn't send the digest of user %s: %v", user.ID, err)
+					continue
+				}
+				sent++
+			}
+
+			// nothing to tell counts as sent, the period is over all the same.
+			// Failing here sends the digest again after the lease, better than not at all
+			err = cfg.db.MarkDigestSent(ctx, database.MarkDigestSentParams{
+				SentAt: now,
+				ID:     user.ID,
+			})
+			if err != nil {
+				log.Printf("couldn't mark the digest of user %s as sent: %v", user.ID, err)
+			}
+		}
+
+		if len(users) < digestBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// runDigestSender runs sendDueDigests every interval until the context is cancelled
+func (cfg *apiConfig) runDigestSender(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := cfg.sendDueDigests(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("couldn't send the digests: %v", err)
+			}
+			if sent > 0 {
+				log.Printf("sent %d digests", sent)
+			}
+		}
+	}
+}
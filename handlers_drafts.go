@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxDraftBytes caps the draft body, drafts can go over the chirp length limit while being written
+const maxDraftBytes = 10_000
+
+func draftResponse(draft database.Draft) map[string]any {
+	return map[string]any{
+		"id":         draft.ID,
+		"user_id":    draft.UserID,
+		"body":       draft.Body,
+		"media_ids":  draft.MediaIds,
+		"created_at": draft.CreatedAt,
+		"updated_at": draft.UpdatedAt,
+	}
+}
+
+type draftRequest struct {
+	Body     string   `json:"body"`
+	MediaIDs []string `json:"media_ids"`
+}
+
+// decodeDraft reads & validates the draft from the request body, writing the error response itself.
+// Drafts are unfinished on purpose, the chirp rules only apply when they're published.
+func decodeDraft(rw http.ResponseWriter, r *http.Request) (string, []uuid.UUID, bool) {
+	var draftReq draftRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&draftReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return "", nil, false
+	}
+	defer r.Body.Close()
+
+	if len(draftReq.Body) > maxDraftBytes {
+		writeErrorResponse(rw, 400, fmt.Sprintf("draft body too long, the limit is %d bytes", maxDraftBytes))
+		return "", nil, false
+	}
+
+	if len(draftReq.MediaIDs) > maxChirpAttachments {
+		writeErrorResponse(rw, 400, fmt.Sprintf("a chirp can have at most %d images", maxChirpAttachments))
+		return "", nil, false
+	}
+
+	mediaUUIDs := make([]uuid.UUID, 0, len(draftReq.MediaIDs))
+	for _, mediaID := range draftReq.MediaIDs {
+		mediaUUID, err := validateUUID(mediaID, "media ID")
+		if err != nil {
+			writeErrorResponse(rw, 400, err.Error())
+			return "", nil, false
+		}
+		mediaUUIDs = append(mediaUUIDs, mediaUUID)
+	}
+
+	return draftReq.Body, mediaUUIDs, true
+}
+
+func (cfg *apiConfig) handlerCreateDraft(rw http.ResponseWriter, r *http.Request) {
+	body, mediaUUIDs, ok := decodeDraft(rw, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	draft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		Body:      body,
+		MediaIds:  mediaUUIDs,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't create the draft")
+		return
+	}
+
+	writeSuccessResponse(rw, 201, draftResponse(draft))
+}
+
+func (cfg *apiConfig) handlerListDrafts(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	drafts, err := cfg.db.ListDrafts(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the drafts")
+		return
+	}
+
+	draftsResponseJson := make([]map[string]any, len(drafts))
+	for i, draft := range drafts {
+		draftsResponseJson[i] = draftResponse(draft)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"drafts": draftsResponseJson,
+	})
+}
+
+func (cfg *apiConfig) handlerGetDraft(rw http.ResponseWriter, r *http.Request) {
+	draftUUID, err := validateUUID(r.PathValue("draftID"), "draft ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// someone else's draft is reported as missing
+	draft, err := cfg.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "draft not found")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, draftResponse(draft))
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(rw http.ResponseWriter, r *http.Request) {
+	draftUUID, err := validateUUID(r.PathValue("draftID"), "draft ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	body, mediaUUIDs, ok := decodeDraft(rw, r)
+	if !ok {
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	draft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		Body:      body,
+		MediaIds:  mediaUUIDs,
+		UpdatedAt: time.Now(),
+		ID:        draftUUID,
+		UserID:    userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "draft not found")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, draftResponse(draft))
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(rw http.ResponseWriter, r *http.Request) {
+	draftUUID, err := validateUUID(r.PathValue("draftID"), "draft ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	deleted, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't delete the draft")
+		return
+	}
+	if deleted == 0 {
+		writeErrorResponse(rw, 404, "draft not found")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+// handlerPublishDraft turns the draft into a chirp through the same pipeline as POST /api/chirps,
+// the draft is deleted in the chirp's transaction so it can't be published twice
+func (cfg *apiConfig) handlerPublishDraft(rw http.ResponseWriter, r *http.Request) {
+	draftUUID, err := validateUUID(r.PathValue("draftID"), "draft ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	draft, err := cfg.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftUUID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "draft not found")
+		return
+	}
+
+	chirp, attachments, err := cfg.createChirp(r.Context(), user, chirpInput{
+		Body:     draft.Body,
+		MediaIDs: draft.MediaIds,
+	}, func(qtx *database.Queries) error {
+		deleted, err := qtx.DeleteDraft(r.Context(), database.DeleteDraftParams{
+			ID:     draft.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		// published by a concurrent request in the meantime
+		if deleted == 0 {
+			return &chirpError{409, "draft was already published"}
+		}
+		return nil
+	})
+	if err != nil {
+		writeChirpError(rw, err, "couldn't publish the draft")
+		return
+	}
+
+	writeSuccessResponse(rw, 201, cfg.chirpResponse(chirp, attachments))
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+)
+
+const (
+	followStatusPending  = "pending"
+	followStatusAccepted = "accepted"
+)
+
+func followResponse(follow database.Follow) map[string]any {
+	return map[string]any{
+		"follower_id": follow.FollowerID,
+		"followee_id": follow.FolloweeID,
+		"status":      follow.Status,
+		"created_at":  follow.CreatedAt,
+		"updated_at":  follow.UpdatedAt,
+	}
+}
+
+// handlerSetProtected turns the protected account flag on or off.
+// Turning it off approves the pending follow requests, there's nothing left to approve them for.
+func (cfg *apiConfig) handlerSetProtected(rw http.ResponseWriter, r *http.Request) {
+	type ProtectedReq struct {
+		IsProtected *bool `json:"is_protected"`
+	}
+
+	var protectedReq ProtectedReq
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&protectedReq)
+	if err != nil || protectedReq.IsProtected == nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	updatedUser, err := qtx.SetUserProtected(r.Context(), database.SetUserProtectedParams{
+		IsProtected: *protectedReq.IsProtected,
+		UpdatedAt:   time.Now(),
+		ID:          userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the user")
+		return
+	}
+
+	if !updatedUser.IsProtected {
+		err = qtx.ApproveAllFollowRequests(r.Context(), database.ApproveAllFollowRequestsParams{
+			UpdatedAt:  time.Now(),
+			FolloweeID: userUUID,
+		})
+		if err != nil {
+			writeErrorResponse(rw, 500, "couldn't approve the follow requests")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(rw, 500, "couldn't update the user")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"id":           updatedUser.ID,
+		"is_protected": updatedUser.IsProtected,
+		"updated_at":   updatedUser.UpdatedAt,
+	})
+}
+
+// handlerFollowUser follows a user right away, or sends a follow request when their account is protected
+func (cfg *apiConfig) handlerFollowUser(rw http.ResponseWriter, r *http.Request) {
+	userUUID, targetUUID, ok := cfg.relationTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	target, err := cfg.db.GetUserByID(r.Context(), targetUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "user not found")
+		return
+	}
+
+	// blocks go both ways for follows
+	blocked, err := cfg.isBlocked(r.Context(), targetUUID, userUUID)
+	if err == nil && !blocked {
+		blocked, err = cfg.isBlocked(r.Context(), userUUID, targetUUID)
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't follow the user")
+		return
+	}
+	if blocked {
+		writeErrorResponse(rw, 403, "you can't follow this user")
+		return
+	}
+
+	// following again returns the existing follow / request as is, without notifying again
+	existing, err := cfg.db.GetFollow(r.Context(), database.GetFollowParams{
+		FollowerID: userUUID,
+		FolloweeID: targetUUID,
+	})
+	if err == nil {
+		writeSuccessResponse(rw, 200, followResponse(existing))
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 500, "couldn't follow the user")
+		return
+	}
+
+	status := followStatusAccepted
+	if target.IsProtected {
+		status = followStatusPending
+	}
+
+	follow, err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userUUID,
+		FolloweeID: targetUUID,
+		Status:     status,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't follow the user")
+		return
+	}
+
+	if follow.Status == followStatusPending {
+		cfg.notify(targetUUID, notificationFollowRequest, userUUID)
+	} else {
+		cfg.notify(targetUUID, notificationFollow, userUUID)
+	}
+
+	writeSuccessResponse(rw, 200, followResponse(follow))
+}
+
+// handlerUnfollowUser unfollows a user, or cancels a pending follow request
+func (cfg *apiConfig) handlerUnfollowUser(rw http.ResponseWriter, r *http.Request) {
+	userUUID, targetUUID, ok := cfg.relationTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	unfollowed, err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userUUID,
+		FolloweeID: targetUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unfollow the user")
+		return
+	}
+	if unfollowed == 0 {
+		writeErrorResponse(rw, 404, "you don't follow this user")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+// handlerListFollowRequests lists the pending requests to follow the user, oldest first
+func (cfg *apiConfig) handlerListFollowRequests(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	requests, err := cfg.db.ListFollowRequests(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the follow requests")
+		return
+	}
+
+	requestsResponseJson := make([]map[string]any, len(requests))
+	for i, request := range requests {
+		requestsResponseJson[i] = followResponse(request)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"follow_requests": requestsResponseJson,
+	})
+}
+
+func (cfg *apiConfig) handlerApproveFollowRequest(rw http.ResponseWriter, r *http.Request) {
+	userUUID, followerUUID, ok := cfg.relationTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	approved, err := cfg.db.ApproveFollowRequest(r.Context(), database.ApproveFollowRequestParams{
+		UpdatedAt:  time.Now(),
+		FollowerID: followerUUID,
+		FolloweeID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't approve the follow request")
+		return
+	}
+	if approved == 0 {
+		writeErrorResponse(rw, 404, "follow request not found")
+		return
+	}
+
+	cfg.notify(followerUUID, notificationFollowAccepted, userUUID)
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerDenyFollowRequest(rw http.ResponseWriter, r *http.Request) {
+	userUUID, followerUUID, ok := cfg.relationTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	denied, err := cfg.db.DenyFollowRequest(r.Context(), database.DenyFollowRequestParams{
+		FollowerID: followerUUID,
+		FolloweeID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't deny the follow request")
+		return
+	}
+	if denied == 0 {
+		writeErrorResponse(rw, 404, "follow request not found")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
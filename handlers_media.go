@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/media"
+	"github.com/MeYo0o/chirpy_server/internal/storage"
+	"github.com/google/uuid"
+)
+
+// maxChirpAttachments is the number of images a single chirp can carry
+const maxChirpAttachments = 4
+
+// publicCacheMaxAge is how long clients & CDNs can serve a public media without asking again
+const publicCacheMaxAge = time.Minute * 5
+
+// multipartOverhead is the room left for the multipart boundaries & text fields on top of the files
+const multipartOverhead = 1 << 20
+
+func (cfg *apiConfig) mediaURL(attachment database.MediaAttachment, variant string) string {
+	url := fmt.Sprintf("%s/api/media/%s", cfg.mediaBaseURL, attachment.ID)
+	if variant != "" {
+		url += "/" + variant
+	}
+	return url
+}
+
+func (cfg *apiConfig) mediaAttachmentResponse(attachment database.MediaAttachment) map[string]any {
+	return map[string]any{
+		"id":            attachment.ID,
+		"url":           cfg.mediaURL(attachment, ""),
+		"thumbnail_url": cfg.mediaURL(attachment, "thumbnail"),
+		"content_type":  attachment.ContentType,
+		"width":         attachment.Width,
+		"height":        attachment.Height,
+	}
+}
+
+// readUpload reads a multipart file into memory, refusing files bigger than maxBytes
+func readUpload(fileHeader *multipart.FileHeader, maxBytes int64) ([]byte, error) {
+	if fileHeader.Size > maxBytes {
+		return nil, fmt.Errorf("%s is too large, the limit is %d bytes", fileHeader.Filename, maxBytes)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s", fileHeader.Filename)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s", fileHeader.Filename)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%s is too large, the limit is %d bytes", fileHeader.Filename, maxBytes)
+	}
+
+	return data, nil
+}
+
+// storeMedia processes the uploaded image and stores it with its thumbnail, not attached to any chirp yet.
+// Invalid images return a media error (media.ErrUnsupportedType, ...) the caller can report as a client error.
+func (cfg *apiConfig) storeMedia(ctx context.Context, userID uuid.UUID, data []byte) (database.MediaAttachment, error) {
+	img, err := media.Process(data, media.Options{
+		MaxPixels:          cfg.mediaMaxPixels,
+		MaxFrames:          cfg.mediaMaxFrames,
+		MaxAnimationPixels: cfg.mediaMaxAnimationPixels,
+		ThumbnailSize:      400,
+	})
+	if err != nil {
+		return database.MediaAttachment{}, err
+	}
+
+	id := uuid.New()
+	storageKey := fmt.Sprintf("media/%s/original", id)
+	thumbnailKey := fmt.Sprintf("media/%s/thumbnail", id)
+
+	if err := cfg.blobs.Put(ctx, storageKey, img.ContentType, img.Data); err != nil {
+		return database.MediaAttachment{}, err
+	}
+	if err := cfg.blobs.Put(ctx, thumbnailKey, img.ThumbnailContentType, img.Thumbnail); err != nil {
+		cfg.deleteBlobs(context.WithoutCancel(ctx), storageKey)
+		return database.MediaAttachment{}, err
+	}
+
+	attachment, err := cfg.db.CreateMediaAttachment(ctx, database.CreateMediaAttachmentParams{
+		ID:                   id,
+		UserID:               userID,
+		ContentType:          img.ContentType,
+		Width:                int32(img.Width),
+		Height:               int32(img.Height),
+		SizeBytes:            int64(len(img.Data)),
+		StorageKey:           storageKey,
+		ThumbnailContentType: img.ThumbnailContentType,
+		ThumbnailKey:         thumbnailKey,
+		CreatedAt:            time.Now(),
+	})
+	if err != nil {
+		cfg.deleteBlobs(context.WithoutCancel(ctx), storageKey, thumbnailKey)
+		return database.MediaAttachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// writeStoreMediaError maps the image processing & storage errors to a response, image problems are the client's fault
+func writeStoreMediaError(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		writeErrorResponse(rw, 415, err.Error())
+	case errors.Is(err, media.ErrTooManyPixels), errors.Is(err, media.ErrTooManyFrames):
+		writeErrorResponse(rw, 400, err.Error())
+	default:
+		log.Printf("couldn't store the media: %v", err)
+		writeErrorResponse(rw, 500, "couldn't store the media")
+	}
+}
+
+// deleteMediaBlobs removes the files of attachments whose rows are gone, failures only leave orphaned files
+func (cfg *apiConfig) deleteMediaBlobs(ctx context.Context, attachments []database.MediaAttachment) {
+	for _, attachment := range attachments {
+		cfg.deleteBlobs(ctx, attachment.StorageKey, attachment.ThumbnailKey)
+	}
+}
+
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := cfg.blobs.Delete(ctx, key); err != nil {
+			log.Printf("couldn't delete the media blob %s: %v", key, err)
+		}
+	}
+}
+
+// discardMedia removes uploads stored for a chirp that failed to be created, the ones attached meanwhile are kept
+func (cfg *apiConfig) discardMedia(ctx context.Context, attachments []database.MediaAttachment) {
+	ids := make([]uuid.UUID, len(attachments))
+	for i, attachment := range attachments {
+		ids[i] = attachment.ID
+	}
+
+	deleted, err := cfg.db.DeleteMediaAttachmentsByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("couldn't discard the media of a failed chirp: %v", err)
+		return
+	}
+	cfg.deleteMediaBlobs(ctx, deleted)
+}
+
+// purgeUnattachedMedia removes the uploads never attached to a chirp within the TTL, along with their files.
+// Batches are locked with FOR UPDATE SKIP LOCKED, so several replicas can run it at the same time.
+func (cfg *apiConfig) purgeUnattachedMedia(ctx context.Context, ttl time.Duration) (int, error) {
+	purged := 0
+	for {
+		deleted, err := cfg.db.DeleteUnattachedMedia(ctx, database.DeleteUnattachedMediaParams{
+			CreatedBefore: time.Now().Add(-ttl),
+			BatchSize:     purgeBatchSize,
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		cfg.deleteMediaBlobs(ctx, deleted)
+		purged += len(deleted)
+		if len(deleted) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// runMediaPurger runs purgeUnattachedMedia every interval until the context is cancelled
+func (cfg *apiConfig) runMediaPurger(ctx context.Context, interval, ttl time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.purgeUnattachedMedia(ctx, ttl)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("couldn't purge the unattached media: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("purged %d unattached media", purged)
+			}
+		}
+	}
+}
+
+// chirpsAttachments loads the attachments of every chirp at once, grouped by chirp ID
+func (cfg *apiConfig) chirpsAttachments(ctx context.Context, chirps []database.Chirp) (map[uuid.UUID][]database.MediaAttachment, error) {
+	chirpIDs := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		chirpIDs[i] = chirp.ID
+	}
+
+	attachments, err := cfg.db.GetMediaAttachmentsByChirpIDs(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byChirp := map[uuid.UUID][]database.MediaAttachment{}
+	for _, attachment := range attachments {
+		byChirp[attachment.ChirpID.UUID] = append(byChirp[attachment.ChirpID.UUID], attachment)
+	}
+
+	return byChirp, nil
+}
+
+// handlerUploadMedia pre-uploads an image (multipart "file" field), its ID can then be sent in a chirp's media_ids
+func (cfg *apiConfig) handlerUploadMedia(rw http.ResponseWriter, r *http.Request) {
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(rw, r.Body, cfg.mediaMaxBytes+multipartOverhead)
+	defer r.Body.Close()
+
+	_, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		writeErrorResponse(rw, 400, "missing the image in the file field, or it's too large")
+		return
+	}
+
+	data, err := readUpload(fileHeader, cfg.mediaMaxBytes)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	attachment, err := cfg.storeMedia(r.Context(), user.ID, data)
+	if err != nil {
+		writeStoreMediaError(rw, err)
+		return
+	}
+
+	writeSuccessResponse(rw, 201, cfg.mediaAttachmentResponse(attachment))
+}
+
+func (cfg *apiConfig) handlerGetMedia(rw http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(rw, r, false)
+}
+
+func (cfg *apiConfig) handlerGetMediaThumbnail(rw http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(rw, r, true)
+}
+
+func (cfg *apiConfig) serveMedia(rw http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaUUID, err := validateUUID(r.PathValue("mediaID"), "media ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	viewerID, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// the media follows the visibility of its chirp, an upload not attached yet is only visible to its owner
+	attachment, err := cfg.db.GetVisibleMediaAttachment(r.Context(), database.GetVisibleMediaAttachmentParams{
+		ID:       mediaUUID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "media not found")
+		return
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail {
+		key, contentType = attachment.ThumbnailKey, attachment.ThumbnailContentType
+	}
+
+	cfg.serveBlob(rw, r, key, contentType, attachment.CreatedAt, attachment.Public)
+}
+
+// serveBlob writes an immutable blob from the storage, the way handlerLogo serves the files on disk.
+// Caches must come back after publicCacheMaxAge for a public blob, right away for the others, so a change of
+// visibility (a hidden or deleted chirp, a block, ...) applies. The ETag keeps the revalidations cheap.
+func (cfg *apiConfig) serveBlob(rw http.ResponseWriter, r *http.Request, key, contentType string, modTime time.Time, public bool) {
+	blob, err := cfg.blobs.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeErrorResponse(rw, 404, "media not found")
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't read the blob %s: %v", key, err)
+		writeErrorResponse(rw, 500, "couldn't read the media")
+		return
+	}
+	defer blob.Close()
+
+	data, err := io.ReadAll(blob)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't read the media")
+		return
+	}
+
+	// a blob key always points to the same bytes, the key is enough of an ETag
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("ETag", fmt.Sprintf("%q", key))
+	if public {
+		rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, must-revalidate", int(publicCacheMaxAge.Seconds())))
+	} else {
+		rw.Header().Set("Cache-Control", "private, no-cache")
+		rw.Header().Add("Vary", "Authorization")
+	}
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(rw, r, "", modTime, bytes.NewReader(data))
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// Direct messages are a separate data path from the chirps: they're only visible to the two participants,
+// and never go through the feed, the reports or the media.
+
+// maxMessageLength caps a message, counted like the chirps (user-perceived characters, links as a fixed length)
+const maxMessageLength = 1000
+
+// otherParticipant returns the participant of the conversation that isn't userID
+func otherParticipant(conversation database.Conversation, userID uuid.UUID) uuid.UUID {
+	if conversation.UserA == userID {
+		return conversation.UserB
+	}
+	return conversation.UserA
+}
+
+func conversationResponse(conversation database.Conversation, userID uuid.UUID) map[string]any {
+	return map[string]any{
+		"id":           conversation.ID,
+		"with_user_id": otherParticipant(conversation, userID),
+		"created_at":   conversation.CreatedAt,
+		"updated_at":   conversation.UpdatedAt,
+	}
+}
+
+func messageResponse(message database.Message) map[string]any {
+	// null until the recipient reads the conversation
+	var readAt *time.Time
+	if message.ReadAt.Valid {
+		readAt = &message.ReadAt.Time
+	}
+
+	return map[string]any{
+		"id":              message.ID,
+		"conversation_id": message.ConversationID,
+		"sender_id":       message.SenderID,
+		"body":            message.Body,
+		"created_at":      message.CreatedAt,
+		"read_at":         readAt,
+	}
+}
+
+// eitherBlocked reports whether one of the two users blocked the other
+func (cfg *apiConfig) eitherBlocked(r *http.Request, userA, userB uuid.UUID) (bool, error) {
+	blocked, err := cfg.isBlocked(r.Context(), userA, userB)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return cfg.isBlocked(r.Context(), userB, userA)
+}
+
+// userConversation reads the {conversationID} of the request and authenticates the user, writing the error response itself.
+// A conversation the user isn't part of is reported as missing.
+func (cfg *apiConfig) userConversation(rw http.ResponseWriter, r *http.Request) (database.Conversation, uuid.UUID, bool) {
+	conversationUUID, err := validateUUID(r.PathValue("conversationID"), "conversation ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return database.Conversation{}, uuid.Nil, false
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return database.Conversation{}, uuid.Nil, false
+	}
+
+	conversation, err := cfg.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		ID:     conversationUUID,
+		UserID: userUUID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 404, "conversation not found")
+		return database.Conversation{}, uuid.Nil, false
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the conversation")
+		return database.Conversation{}, uuid.Nil, false
+	}
+
+	return conversation, userUUID, true
+}
+
+// handlerStartConversation returns the conversation with the user, creating it the first time
+func (cfg *apiConfig) handlerStartConversation(rw http.ResponseWriter, r *http.Request) {
+	type ConversationReq struct {
+		UserID string `json:"user_id"`
+	}
+
+	var conversationReq ConversationReq
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&conversationReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	recipientUUID, err := validateUUID(conversationReq.UserID, "user ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	if recipientUUID == userUUID {
+		writeErrorResponse(rw, 400, "you can't message yourself")
+		return
+	}
+
+	if !cfg.ensureUserExists(rw, r, recipientUUID) {
+		return
+	}
+
+	blocked, err := cfg.eitherBlocked(r, userUUID, recipientUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't start the conversation")
+		return
+	}
+	if blocked {
+		writeErrorResponse(rw, 403, "you can't message this user")
+		return
+	}
+
+	// the pair is stored with the smaller id first
+	userA, userB := userUUID, recipientUUID
+	if userB.String() < userA.String() {
+		userA, userB = userB, userA
+	}
+
+	conversation, err := cfg.db.CreateConversation(r.Context(), database.CreateConversationParams{
+		ID:        uuid.New(),
+		UserA:     userA,
+		UserB:     userB,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't start the conversation")
+		return
+	}
+
+	writeSuccessResponse(rw, 200, conversationResponse(conversation, userUUID))
+}
+
+// handlerListConversations lists the user's conversations, most recently active first
+func (cfg *apiConfig) handlerListConversations(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 20, 100)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	conversations, err := cfg.db.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID: userUUID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the conversations")
+		return
+	}
+
+	total, err := cfg.db.CountConversations(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the conversations")
+		return
+	}
+
+	conversationsResponseJson := make([]map[string]any, len(conversations))
+	for i, row := range conversations {
+		conversationsResponseJson[i] = conversationResponse(database.Conversation{
+			ID:        row.ID,
+			UserA:     row.UserA,
+			UserB:     row.UserB,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		}, userUUID)
+		conversationsResponseJson[i]["unread_count"] = row.UnreadCount
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"conversations": conversationsResponseJson,
+		"total":         total,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+func (cfg *apiConfig) handlerSendMessage(rw http.ResponseWriter, r *http.Request) {
+	type MessageReq struct {
+		Body string `json:"body"`
+	}
+
+	var messageReq MessageReq
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&messageReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := validateRequiredFields(map[string]string{"body": messageReq.Body}); err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	if len(messageReq.Body) > maxTextBytes {
+		writeErrorResponse(rw, 400, fmt.Sprintf("message too large: the limit is %d bytes", maxTextBytes))
+		return
+	}
+	if length := cfg.chirpCounter.Count(messageReq.Body); length > maxMessageLength {
+		writeErrorResponse(rw, 400, fmt.Sprintf("message too long: %d characters, the limit is %d", length, maxMessageLength))
+		return
+	}
+
+	conversation, userUUID, ok := cfg.userConversation(rw, r)
+	if !ok {
+		return
+	}
+
+	// a block in either direction closes the conversation, its history stays readable
+	blocked, err := cfg.eitherBlocked(r, userUUID, otherParticipant(conversation, userUUID))
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+	if blocked {
+		writeErrorResponse(rw, 403, "you can't message this user")
+		return
+	}
+
+	// messages go through the content filter like the chirps, flagging has no review queue for them
+	filtered := cfg.contentFilter.Apply(messageReq.Body)
+	if filtered.Rejected {
+		writeErrorResponse(rw, 400, "message contains blocked content")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	now := time.Now()
+	message, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{
+		ID:             uuid.New(),
+		ConversationID: conversation.ID,
+		SenderID:       userUUID,
+		Body:           filtered.Body,
+		CreatedAt:      now,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+
+	err = qtx.TouchConversation(r.Context(), database.TouchConversationParams{
+		UpdatedAt: now,
+		ID:        conversation.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+
+	// the recipient's streams get the message once it's committed
+	payload, err := json.Marshal(messageResponse(message))
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+	err = qtx.CreateStreamEvent(r.Context(), database.CreateStreamEventParams{
+		UserID:    otherParticipant(conversation, userUUID),
+		Type:      eventMessageCreated,
+		Payload:   payload,
+		CreatedAt: now,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(rw, 500, "couldn't send the message")
+		return
+	}
+
+	writeSuccessResponse(rw, 201, messageResponse(message))
+}
+
+// handlerListMessages returns the conversation history, newest first
+func (cfg *apiConfig) handlerListMessages(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 50, 200)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	conversation, _, ok := cfg.userConversation(rw, r)
+	if !ok {
+		return
+	}
+
+	messages, err := cfg.db.ListMessages(r.Context(), database.ListMessagesParams{
+		ConversationID: conversation.ID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the messages")
+		return
+	}
+
+	total, err := cfg.db.CountMessages(r.Context(), conversation.ID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the messages")
+		return
+	}
+
+	messagesResponseJson := make([]map[string]any, len(messages))
+	for i, message := range messages {
+		messagesResponseJson[i] = messageResponse(message)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"messages": messagesResponseJson,
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+// handlerReadConversation marks the messages the user received in the conversation as read, the sender sees it as read_at
+func (cfg *apiConfig) handlerReadConversation(rw http.ResponseWriter, r *http.Request) {
+	conversation, userUUID, ok := cfg.userConversation(rw, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         sql.NullTime{Time: time.Now(), Valid: true},
+		ConversationID: conversation.ID,
+		SenderID:       userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't mark the conversation as read")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
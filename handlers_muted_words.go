@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/contentfilter"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxMutedWords caps the active muted words of a user, every read of the feed goes through them
+	maxMutedWords = 100
+	// maxMutedWordBytes caps a single muted word / phrase
+	maxMutedWordBytes = 100
+	// mutedWordsRefresh is how often a stream or a WebSocket reloads the viewer's muted words
+	mutedWordsRefresh = time.Minute
+)
+
+func mutedWordResponse(mutedWord database.MutedWord) map[string]any {
+	// null when the word is muted until removed
+	var expiresAt *time.Time
+	if mutedWord.ExpiresAt.Valid {
+		expiresAt = &mutedWord.ExpiresAt.Time
+	}
+
+	return map[string]any{
+		"id":         mutedWord.ID,
+		"phrase":     mutedWord.Phrase,
+		"expires_at": expiresAt,
+		"created_at": mutedWord.CreatedAt,
+	}
+}
+
+func (cfg *apiConfig) handlerCreateMutedWord(rw http.ResponseWriter, r *http.Request) {
+	type MutedWordReq struct {
+		Phrase    string     `json:"phrase"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	var mutedWordReq MutedWordReq
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&mutedWordReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	// matching is case-insensitive, so is the uniqueness of the phrases
+	phrase := strings.ToLower(strings.TrimSpace(mutedWordReq.Phrase))
+	if !contentfilter.ValidPattern(phrase) {
+		writeErrorResponse(rw, 400, "phrase must contain at least one word")
+		return
+	}
+	if len(phrase) > maxMutedWordBytes {
+		writeErrorResponse(rw, 400, fmt.Sprintf("phrase too long, the limit is %d bytes", maxMutedWordBytes))
+		return
+	}
+
+	now := time.Now()
+	var expiresAt sql.NullTime
+	if mutedWordReq.ExpiresAt != nil {
+		if !mutedWordReq.ExpiresAt.After(now) {
+			writeErrorResponse(rw, 400, "expires_at must be in the future")
+			return
+		}
+		// stored like every other timestamp, in the server's local time
+		expiresAt = sql.NullTime{Time: mutedWordReq.ExpiresAt.Local(), Valid: true}
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	count, err := cfg.db.CountActiveMutedWords(r.Context(), database.CountActiveMutedWordsParams{
+		UserID: userUUID,
+		Now:    now,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the muted words")
+		return
+	}
+	if count >= maxMutedWords {
+		writeErrorResponse(rw, 400, fmt.Sprintf("you can mute at most %d words", maxMutedWords))
+		return
+	}
+
+	// no row comes back when the phrase is already muted
+	mutedWord, err := cfg.db.CreateMutedWord(r.Context(), database.CreateMutedWordParams{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		Phrase:    phrase,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		writeErrorResponse(rw, 409, "this phrase is already muted")
+		return
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't mute the phrase")
+		return
+	}
+
+	writeSuccessResponse(rw, 201, mutedWordResponse(mutedWord))
+}
+
+func (cfg *apiConfig) handlerListMutedWords(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// expired words are left out, muting the phrase again replaces them
+	mutedWords, err := cfg.db.ListActiveMutedWords(r.Context(), database.ListActiveMutedWordsParams{
+		UserID: userUUID,
+		Now:    time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the muted words")
+		return
+	}
+
+	mutedWordsResponseJson := make([]map[string]any, len(mutedWords))
+	for i, mutedWord := range mutedWords {
+		mutedWordsResponseJson[i] = mutedWordResponse(mutedWord)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"muted_words": mutedWordsResponseJson,
+	})
+}
+
+func (cfg *apiConfig) handlerDeleteMutedWord(rw http.ResponseWriter, r *http.Request) {
+	mutedWordUUID, err := validateUUID(r.PathValue("mutedWordID"), "muted word ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	deleted, err := cfg.db.DeleteMutedWord(r.Context(), database.DeleteMutedWordParams{
+		ID:     mutedWordUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unmute the phrase")
+		return
+	}
+	if deleted == 0 {
+		writeErrorResponse(rw, 404, "muted word not found")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+// mutedWordsFilter returns a filter matching the viewer's active muted words like the content filter rules, nil when there's none
+func (cfg *apiConfig) mutedWordsFilter(ctx context.Context, viewerID uuid.UUID) (*contentfilter.Filter, error) {
+	mutedWords, err := cfg.db.ListActiveMutedWords(ctx, database.ListActiveMutedWordsParams{
+		UserID: viewerID,
+		Now:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(mutedWords) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]contentfilter.Rule, len(mutedWords))
+	for i, mutedWord := range mutedWords {
+		rules[i] = contentfilter.Rule{Pattern: mutedWord.Phrase, Action: contentfilter.ActionMask}
+	}
+	filter := contentfilter.New(nil)
+	filter.SetRules(rules)
+
+	return filter, nil
+}
+
+// withoutMutedWords drops the chirps matching the viewer's active muted words
+func (cfg *apiConfig) withoutMutedWords(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp) ([]database.Chirp, error) {
+	filter, err := cfg.mutedWordsFilter(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return chirps, nil
+	}
+
+	visible := make([]database.Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		if !filter.Matches(chirp.Body) {
+			visible = append(visible, chirp)
+		}
+	}
+
+	return visible, nil
+}
+
+// mutedWordsMatcher drops the chirp.created events matching the viewer's muted words on a long-lived connection.
+// The muted words are reloaded every mutedWordsRefresh rather than queried for every event.
+type mutedWordsMatcher struct {
+	cfg      *apiConfig
+	viewerID uuid.UUID
+	filter   *contentfilter.Filter
+	loadedAt time.Time
+}
+
+func (cfg *apiConfig) newMutedWordsMatcher(viewerID uuid.UUID) *mutedWordsMatcher {
+	return &mutedWordsMatcher{cfg: cfg, viewerID: viewerID}
+}
+
+// mutes reports whether the event is a chirp matching one of the muted words.
+// When they can't be reloaded, the last ones loaded keep applying.
+func (m *mutedWordsMatcher) mutes(ctx context.Context, event events.Event) bool {
+	if event.Type != eventChirpCreated {
+		return false
+	}
+
+	if time.Since(m.loadedAt) >= mutedWordsRefresh {
+		filter, err := m.cfg.mutedWordsFilter(ctx, m.viewerID)
+		if err != nil {
+			log.Printf("couldn't load the muted words of %s: %v", m.viewerID, err)
+		} else {
+			m.filter = filter
+		}
+		m.loadedAt = time.Now()
+	}
+	if m.filter == nil {
+		return false
+	}
+
+	var chirp struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(event.Payload, &chirp); err != nil {
+		return false
+	}
+	return m.filter.Matches(chirp.Body)
+}
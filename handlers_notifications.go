@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// notificationFollow => someone followed you
+	notificationFollow = "follow"
+	// notificationFollowRequest => someone asked to follow your protected account
+	notificationFollowRequest = "follow_request"
+	// notificationFollowAccepted => your follow request was approved
+	notificationFollowAccepted = "follow_accepted"
+	// notificationChirpyRed => your Chirpy Red upgrade went through
+	notificationChirpyRed = "chirpy_red"
+
+	// eventNotificationCreated is pushed to the user's stream with every new notification
+	eventNotificationCreated = "notification.created"
+)
+
+func notificationResponse(notification database.Notification) map[string]any {
+	var actorID *uuid.UUID
+	if notification.ActorID.Valid {
+		actorID = &notification.ActorID.UUID
+	}
+	var readAt *time.Time
+	if notification.ReadAt.Valid {
+		readAt = &notification.ReadAt.Time
+	}
+
+	return map[string]any{
+		"id":         notification.ID,
+		"type":       notification.Type,
+		"actor_id":   actorID,
+		"created_at": notification.CreatedAt,
+		"read_at":    readAt,
+	}
+}
+
+// notify queues a notification for the user, the job worker creates it so the request doesn't wait for it.
+// actorID is the user who triggered it, uuid.Nil for the system notifications.
+func (cfg *apiConfig) notify(userID uuid.UUID, notificationType string, actorID uuid.UUID) {
+	// not tied to the request, what triggered the notification is already done
+	err := enqueueJob(context.Background(), cfg.db, jobNotification, notificationJob{
+		UserID:  userID,
+		Type:    notificationType,
+		ActorID: actorID,
+	})
+	if err != nil {
+		log.Printf("couldn't queue the %s notification of user %s: %v", notificationType, userID, err)
+	}
+}
+
+// createNotification stores the notification and pushes it to the user's stream, given the queries of a transaction
+func (cfg *apiConfig) createNotification(ctx context.Context, q *database.Queries, userID uuid.UUID, notificationType string, actorID uuid.UUID) error {
+	notification, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      notificationType,
+		ActorID:   uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		CreatedAt: time.Now(),
+	})
+	// the user blocked or muted the actor
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(notificationResponse(notification))
+	if err != nil {
+		return err
+	}
+
+	return q.CreateStreamEvent(ctx, database.CreateStreamEventParams{
+		UserID:    userID,
+		Type:      eventNotificationCreated,
+		Payload:   payload,
+		CreatedAt: notification.CreatedAt,
+	})
+}
+
+// handlerListNotifications lists the user's notifications, newest first, with the number of unread ones
+func (cfg *apiConfig) handlerListNotifications(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 20, 100)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	// [Optional] unread=true => only the unread notifications
+	unreadOnly := false
+	switch r.URL.Query().Get("unread") {
+	case "", "false":
+	case "true":
+		unreadOnly = true
+	default:
+		writeErrorResponse(rw, 400, "unread must be true or false")
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	notifications, err := cfg.db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     userUUID,
+		UnreadOnly: unreadOnly,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the notifications")
+		return
+	}
+
+	total, err := cfg.db.CountNotifications(r.Context(), database.CountNotificationsParams{
+		UserID:     userUUID,
+		UnreadOnly: unreadOnly,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the notifications")
+		return
+	}
+
+	unreadCount, err := cfg.db.CountUnreadNotifications(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the notifications")
+		return
+	}
+
+	notificationsResponseJson := make([]map[string]any, len(notifications))
+	for i, notification := range notifications {
+		notificationsResponseJson[i] = notificationResponse(notification)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"notifications": notificationsResponseJson,
+		"unread_count":  unreadCount,
+		"total":         total,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+func (cfg *apiConfig) handlerReadNotification(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	notificationUUID, err := validateUUID(r.PathValue("notificationID"), "notification ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	// reading it again keeps the first read time
+	marked, err := cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ReadAt: time.Now(),
+		ID:     notificationUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't mark the notification as read")
+		return
+	}
+	if marked == 0 {
+		writeErrorResponse(rw, 404, "notification not found")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerReadAllNotifications(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	_, err = cfg.db.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't mark the notifications as read")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerPinChirp pins one of the user's chirps to their profile, replacing the previous pin
+func (cfg *apiConfig) handlerPinChirp(rw http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// Check if chirp exists and belongs to user
+	chirp, err := cfg.db.GetChirpyByUserID(r.Context(), database.GetChirpyByUserIDParams{
+		ID:     chirpUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 403, "chirp not found")
+		return
+	}
+
+	// scheduled & hidden chirps aren't on the profile, so they can't be pinned
+	if chirp.HiddenAt.Valid || !chirp.PublishedAt.Valid {
+		writeErrorResponse(rw, 409, "only published chirps can be pinned")
+		return
+	}
+
+	err = cfg.db.PinChirp(r.Context(), database.PinChirpParams{
+		PinnedChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		UpdatedAt:     time.Now(),
+		ID:            userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't pin the chirp")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+func (cfg *apiConfig) handlerUnpinChirp(rw http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	unpinned, err := cfg.db.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UpdatedAt:     time.Now(),
+		ID:            userUUID,
+		PinnedChirpID: uuid.NullUUID{UUID: chirpUUID, Valid: true},
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't unpin the chirp")
+		return
+	}
+	if unpinned == 0 {
+		writeErrorResponse(rw, 404, "this chirp isn't pinned")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+// pinnedChirpFirst moves the pinned chirp (if it's in the list) to the front, the others keep their order
+func pinnedChirpFirst(chirps []database.Chirp, pinnedChirpID uuid.NullUUID) []database.Chirp {
+	if !pinnedChirpID.Valid {
+		return chirps
+	}
+
+	for i, chirp := range chirps {
+		if chirp.ID == pinnedChirpID.UUID {
+			copy(chirps[1:i+1], chirps[:i])
+			chirps[0] = chirp
+			break
+		}
+	}
+
+	return chirps
+}
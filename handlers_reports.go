@@ -0,0 +1,370 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/auth"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// reportReasons are the reason codes a chirp can be reported for, kept in sync with the chirp_reports CHECK constraint
+var reportReasons = []string{"spam", "harassment", "hate_speech", "violence", "sexual_content", "misinformation", "other"}
+
+// moderationActions are the decisions a moderator can take on a report
+var moderationActions = []string{"dismiss", "hide", "delete", "suspend"}
+
+func reportResponse(report database.ChirpReport) map[string]any {
+	var chirpID *uuid.UUID
+	if report.ChirpID.Valid {
+		chirpID = &report.ChirpID.UUID
+	}
+
+	// the author's account may have been deleted since
+	var chirpAuthorID *uuid.UUID
+	if report.ChirpAuthorID.Valid {
+		chirpAuthorID = &report.ChirpAuthorID.UUID
+	}
+
+	// reports raised by the content filter have no reporter, and a reporter may have deleted their account
+	var reporterID *uuid.UUID
+	if report.ReporterID.Valid {
+		reporterID = &report.ReporterID.UUID
+	}
+
+	var resolvedAt *time.Time
+	if report.ResolvedAt.Valid {
+		resolvedAt = &report.ResolvedAt.Time
+	}
+
+	return map[string]any{
+		"id":              report.ID,
+		"chirp_id":        chirpID,
+		"chirp_author_id": chirpAuthorID,
+		"chirp_body":      report.ChirpBody,
+		"reporter_id":     reporterID,
+		"reason":          report.Reason,
+		"details":         report.Details,
+		"status":          report.Status,
+		"created_at":      report.CreatedAt,
+		"updated_at":      report.UpdatedAt,
+		"resolved_at":     resolvedAt,
+	}
+}
+
+func moderationActionResponse(action database.ModerationAction) map[string]any {
+	// kept when the moderator's account is deleted, without the moderator
+	var moderatorID *uuid.UUID
+	if action.ModeratorID.Valid {
+		moderatorID = &action.ModeratorID.UUID
+	}
+
+	return map[string]any{
+		"id":           action.ID,
+		"report_id":    action.ReportID,
+		"moderator_id": moderatorID,
+		"action":       action.Action,
+		"note":         action.Note,
+		"created_at":   action.CreatedAt,
+	}
+}
+
+func (cfg *apiConfig) handlerReportChirp(rw http.ResponseWriter, r *http.Request) {
+	type ReportRequest struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	var reportReq ReportRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&reportReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	if !slices.Contains(reportReasons, reportReq.Reason) {
+		writeErrorResponse(rw, 400, "reason must be one of: "+strings.Join(reportReasons, ", "))
+		return
+	}
+
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	// Validate JWT and get user UUID
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// only chirps the user can see can be reported, the others don't exist for them
+	chirp, err := cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpUUID,
+		ViewerID: uuid.NullUUID{UUID: userUUID, Valid: true},
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "Chirp not found")
+		return
+	}
+
+	if chirp.UserID == userUUID {
+		writeErrorResponse(rw, 400, "you can't report your own chirp")
+		return
+	}
+
+	report, err := cfg.db.CreateChirpReport(r.Context(), database.CreateChirpReportParams{
+		ID:            uuid.New(),
+		ChirpID:       uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpAuthorID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		ChirpBody:     chirp.Body,
+		ReporterID:    uuid.NullUUID{UUID: userUUID, Valid: true},
+		Reason:        reportReq.Reason,
+		Details:       strings.TrimSpace(reportReq.Details),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			writeErrorResponse(rw, 409, "you already reported this chirp")
+			return
+		}
+		writeErrorResponse(rw, 500, "couldn't report the chirp")
+		return
+	}
+
+	writeSuccessResponse(rw, 201, map[string]any{
+		"id":         report.ID,
+		"chirp_id":   chirp.ID,
+		"reason":     report.Reason,
+		"details":    report.Details,
+		"status":     report.Status,
+		"created_at": report.CreatedAt,
+	})
+}
+
+func (cfg *apiConfig) handlerModerationListReports(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 20, 100)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	// the queue shows the open reports, oldest first, unless asked otherwise
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	if status != "open" && status != "resolved" {
+		writeErrorResponse(rw, 400, "status must be open or resolved")
+		return
+	}
+
+	reports, err := cfg.db.ListChirpReports(r.Context(), database.ListChirpReportsParams{
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch reports")
+		return
+	}
+
+	total, err := cfg.db.CountChirpReports(r.Context(), status)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count reports")
+		return
+	}
+
+	reportsResponseJson := make([]map[string]any, len(reports))
+	for i, report := range reports {
+		reportsResponseJson[i] = reportResponse(report)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"reports": reportsResponseJson,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+func (cfg *apiConfig) handlerModerationGetReport(rw http.ResponseWriter, r *http.Request) {
+	reportUUID, err := validateUUID(r.PathValue("reportID"), "report ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	report, err := cfg.db.GetChirpReport(r.Context(), reportUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "report not found")
+		return
+	}
+
+	actions, err := cfg.db.GetModerationActionsByReportID(r.Context(), report.ID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the report's moderation history")
+		return
+	}
+
+	actionsResponseJson := make([]map[string]any, len(actions))
+	for i, action := range actions {
+		actionsResponseJson[i] = moderationActionResponse(action)
+	}
+
+	reportResponseJson := reportResponse(report)
+	reportResponseJson["actions"] = actionsResponseJson
+
+	writeSuccessResponse(rw, 200, reportResponseJson)
+}
+
+// handlerModerationResolveReport applies the moderator's decision and records it, all in a single transaction
+func (cfg *apiConfig) handlerModerationResolveReport(rw http.ResponseWriter, r *http.Request) {
+	type ResolveRequest struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+		// only used by the suspend action
+		HideChirps bool `json:"hide_chirps"`
+	}
+
+	var resolveReq ResolveRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&resolveReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	if !slices.Contains(moderationActions, resolveReq.Action) {
+		writeErrorResponse(rw, 400, "action must be one of: "+strings.Join(moderationActions, ", "))
+		return
+	}
+
+	resolveReq.Note = strings.TrimSpace(resolveReq.Note)
+	if err := validateRequiredFields(map[string]string{"note": resolveReq.Note}); err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	reportUUID, err := validateUUID(r.PathValue("reportID"), "report ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	moderator, _ := userFromContext(r.Context())
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't start the moderation transaction")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// the row lock keeps two moderators from resolving the same report at once
+	report, err := qtx.GetChirpReportForUpdate(r.Context(), reportUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "report not found")
+		return
+	}
+
+	if report.Status != "open" {
+		writeErrorResponse(rw, 409, "report is already resolved")
+		return
+	}
+
+	switch resolveReq.Action {
+	case "hide":
+		if report.ChirpID.Valid {
+			err = qtx.HideChirp(r.Context(), database.HideChirpParams{
+				HiddenAt:  sql.NullTime{Time: time.Now(), Valid: true},
+				UpdatedAt: time.Now(),
+				ID:        report.ChirpID.UUID,
+			})
+		}
+	case "delete":
+		// soft deleted by the moderator, so the author can't restore it, the retention job purges it later
+		if report.ChirpID.Valid && report.ChirpAuthorID.Valid {
+			_, err = qtx.SoftDeleteChirp(r.Context(), database.SoftDeleteChirpParams{
+				DeletedAt: sql.NullTime{Time: time.Now(), Valid: true},
+				DeletedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+				UpdatedAt: time.Now(),
+				ID:        report.ChirpID.UUID,
+				UserID:    report.ChirpAuthorID.UUID,
+			})
+		}
+	case "suspend":
+		// the author deleted their account, there's no one left to suspend
+		if !report.ChirpAuthorID.Valid {
+			break
+		}
+		var author database.User
+		author, err = qtx.GetUserByID(r.Context(), report.ChirpAuthorID.UUID)
+		if err != nil {
+			break
+		}
+		if author.ID == moderator.ID {
+			writeErrorResponse(rw, 403, "moderators can't suspend themselves")
+			return
+		}
+		if auth.Role(author.Role).Satisfies(auth.RoleModerator) && !auth.Role(moderator.Role).Satisfies(auth.RoleAdmin) {
+			writeErrorResponse(rw, 403, "only admins can suspend moderators or admins")
+			return
+		}
+		_, err = suspendUser(r.Context(), qtx, author, resolveReq.Note, resolveReq.HideChirps)
+	}
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't apply the moderation action")
+		return
+	}
+
+	action, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ID:          uuid.New(),
+		ReportID:    report.ID,
+		ModeratorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Action:      resolveReq.Action,
+		Note:        resolveReq.Note,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't record the moderation action")
+		return
+	}
+
+	resolvedReport, err := qtx.ResolveChirpReport(r.Context(), database.ResolveChirpReportParams{
+		ResolvedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt:  time.Now(),
+		ID:         report.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't resolve the report")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeErrorResponse(rw, 500, "couldn't commit the moderation action")
+		return
+	}
+
+	reportResponseJson := reportResponse(resolvedReport)
+	reportResponseJson["actions"] = []map[string]any{moderationActionResponse(action)}
+
+	writeSuccessResponse(rw, 200, reportResponseJson)
+}
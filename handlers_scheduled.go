@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxScheduleAhead is how far in the future a chirp can be scheduled
+const maxScheduleAhead = time.Hour * 24 * 365
+
+// publishBatchSize is the number of chirps a replica locks & publishes per query
+const publishBatchSize = 100
+
+func (cfg *apiConfig) handlerListScheduledChirps(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	chirps, err := cfg.db.ListScheduledChirps(r.Context(), userUUID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the scheduled chirps")
+		return
+	}
+
+	attachments, err := cfg.chirpsAttachments(r.Context(), chirps)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirps' media")
+		return
+	}
+
+	chirpsResponseJson := make([]map[string]any, len(chirps))
+	for i, chirp := range chirps {
+		chirpsResponseJson[i] = cfg.chirpResponse(chirp, attachments[chirp.ID])
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"chirps": chirpsResponseJson,
+	})
+}
+
+func (cfg *apiConfig) handlerCancelScheduledChirp(rw http.ResponseWriter, r *http.Request) {
+	chirpUUID, err := validateUUID(r.PathValue("chirpID"), "chirp ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	attachments, err := cfg.db.GetMediaAttachmentsByChirpIDs(r.Context(), []uuid.UUID{chirpUUID})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the chirp's media")
+		return
+	}
+
+	// a chirp being published holds a row lock, the delete waits for it and then finds nothing to cancel
+	cancelled, err := cfg.db.CancelScheduledChirp(r.Context(), database.CancelScheduledChirpParams{
+		ID:     chirpUUID,
+		UserID: userUUID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't cancel the scheduled chirp")
+		return
+	}
+	if cancelled == 0 {
+		writeErrorResponse(rw, 404, "scheduled chirp not found, or already published")
+		return
+	}
+
+	cfg.deleteMediaBlobs(r.Context(), attachments)
+
+	writeEmptyResponse(rw, 204)
+}
+
+// publishDueChirps publishes every scheduled chirp whose time has come.
+// Each batch is locked with FOR UPDATE SKIP LOCKED, so replicas running at the same time split the work
+// instead of publishing the same chirps twice.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		count, err := cfg.publishDueChirpsBatch(ctx)
+		if err != nil {
+			return published, err
+		}
+
+		published += count
+		if count < publishBatchSize {
+			return published, nil
+		}
+	}
+}
+
+// publishDueChirpsBatch publishes a batch, their fan-out jobs are queued in the same transaction
+func (cfg *apiConfig) publishDueChirpsBatch(ctx context.Context) (int, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirps, err := qtx.PublishDueChirps(ctx, database.PublishDueChirpsParams{
+		Now:       time.Now(),
+		BatchSize: publishBatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, chirp := range chirps {
+		err := enqueueJob(ctx, qtx, jobChirpPublished, chirpPublishedJob{ChirpID: chirp.ID, UserID: chirp.UserID})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(chirps), tx.Commit()
+}
+
+// runChirpPublisher runs publishDueChirps every interval until the context is cancelled
+func (cfg *apiConfig) runChirpPublisher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			published, err := cfg.publishDueChirps(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("couldn't publish the scheduled chirps: %v", err)
+			}
+			if published > 0 {
+				log.Printf("published %d scheduled chirps", published)
+			}
+		}
+	}
+}
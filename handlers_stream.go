@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/events"
+)
+
+const (
+	// eventChirpCreated is sent to the followers of the author when a chirp is published
+	eventChirpCreated = "chirp.created"
+	// eventMessageCreated is sent to the recipient of a direct message
+	eventMessageCreated = "message.created"
+	// eventTyping is sent (without being stored) to the other participant of a conversation
+	eventTyping = "typing"
+	// eventResync tells a client resuming with Last-Event-ID that it missed too many events to replay them,
+	// it has to reload its state through the REST API
+	eventResync = "resync"
+	// streamReplayLimit caps the stored events sent to a client resuming with Last-Event-ID
+	streamReplayLimit = 1000
+	// streamReorderWindow is how far behind the cursor a resuming client gets the events again: the IDs are
+	// assigned before the events are committed, so a lower ID can show up after a higher one
+	streamReorderWindow = time.Second * 10
+	// streamRecentIDs is the number of event IDs a stream remembers to skip the ones it already sent
+	streamRecentIDs = streamReplayLimit * 2
+	// streamBufferSize is the number of events a slow client can fall behind before it's disconnected
+	streamBufferSize = 64
+	// streamRetryMillis tells the clients how long to wait before reconnecting
+	streamRetryMillis = 3000
+)
+
+func streamEvent(event database.StreamEvent) events.Event {
+	return events.Event{
+		ID:        event.ID,
+		UserID:    event.UserID,
+		Type:      event.Type,
+		Payload:   event.Payload,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// loadStreamEvent is the loader the Postgres bridge uses for the notified events
+func (cfg *apiConfig) loadStreamEvent(ctx context.Context, id int64) (events.Event, error) {
+	event, err := cfg.db.GetStreamEvent(ctx, id)
+	if err != nil {
+		return events.Event{}, err
+	}
+	return streamEvent(event), nil
+}
+
+// notifyFollowers stores a chirp.created event for the followers of the author, every replica then pushes it
+// to the connected clients
+func (cfg *apiConfig) notifyFollowers(ctx context.Context, q *database.Queries, chirp database.Chirp, attachments []database.MediaAttachment) error {
+	payload, err := json.Marshal(cfg.chirpResponse(chirp, attachments))
+	if err != nil {
+		return err
+	}
+
+	return q.CreateFollowerEvents(ctx, database.CreateFollowerEventsParams{
+		Type:      eventChirpCreated,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+		AuthorID:  chirp.UserID,
+	})
+}
+
+// writeStreamEvent writes the event in the text/event-stream format
+func writeStreamEvent(rw http.ResponseWriter, event events.Event) error {
+	_, err := fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Payload)
+	return err
+}
+
+// handlerStream pushes the user's events as Server-Sent Events until the client goes away.
+// A client reconnecting with Last-Event-ID (header, or last_event_id query param) first gets the stored events it missed.
+func (cfg *apiConfig) handlerStream(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	// a fresh connection only gets the new events
+	var lastEventID int64 = -1
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventIDStr != "" {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || lastEventID < 0 {
+			writeErrorResponse(rw, 400, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	// subscribe before replaying, so nothing stored in between is lost (duplicates are skipped by ID)
+	sub := cfg.events.Subscribe(userUUID)
+	defer sub.Close()
+	sent := events.NewRecentIDs(streamRecentIDs)
+
+	muted := cfg.newMutedWordsMatcher(userUUID)
+
+	rc := http.NewResponseController(rw)
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	// proxies like nginx would otherwise buffer the stream
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(200)
+
+	if _, err := fmt.Fprintf(rw, "retry: %d\n\n", streamRetryMillis); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("the stream can't be flushed: %v", err)
+		return
+	}
+
+	if lastEventID >= 0 {
+		// the events committed late around the cursor are sent again, the client skips the ones it has by ID
+		overlapSince := time.Now().Add(-streamReorderWindow)
+		if cursor, err := cfg.db.GetStreamEvent(r.Context(), lastEventID); err == nil && cursor.UserID == userUUID {
+			overlapSince = cursor.CreatedAt.Add(-streamReorderWindow)
+		}
+
+		missed, err := cfg.db.ListStreamEventsForReplay(r.Context(), database.ListStreamEventsForReplayParams{
+			UserID:       userUUID,
+			AfterID:      lastEventID,
+			OverlapSince: overlapSince,
+			Limit:        streamReplayLimit + 1,
+		})
+		if err != nil {
+			log.Printf("couldn't replay the stream events of %s: %v", userUUID, err)
+			return
+		}
+
+		truncated := len(missed) > streamReplayLimit
+		if truncated {
+			missed = missed[:streamReplayLimit]
+		}
+
+		for _, event := range missed {
+			if !sent.Add(event.ID) || muted.mutes(r.Context(), streamEvent(event)) {
+				continue
+			}
+			if err := writeStreamEvent(rw, streamEvent(event)); err != nil {
+				return
+			}
+		}
+
+		// the events past the limit are skipped, rather than letting the client believe it's up to date
+		if truncated {
+			if _, err := fmt.Fprintf(rw, "event: %s\ndata: {}\n\n", eventResync); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+
+	// comments keep the connection open through proxies, and notice a client that's gone
+	heartbeat := time.NewTicker(cfg.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(rw, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			// closed when the client fell behind or the server shuts down, it resumes from its Last-Event-ID
+			if !ok {
+				return
+			}
+			// ephemeral events (typing indicators) are only sent over the WebSocket.
+			// A lower ID than the last one sent isn't a duplicate, it may just have been committed later
+			if event.Ephemeral() || !sent.Add(event.ID) || muted.mutes(r.Context(), event) {
+				continue
+			}
+			if err := writeStreamEvent(rw, event); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// runStreamEventPurger removes the stream events older than the retention every interval, until the context is cancelled
+func (cfg *apiConfig) runStreamEventPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := cfg.db.DeleteStreamEventsBefore(ctx, time.Now().Add(-retention))
+			if err != nil && ctx.Err() == nil {
+				log.Printf("couldn't purge the stream events: %v", err)
+			}
+		}
+	}
+}
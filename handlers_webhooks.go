@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/auth"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// Outbound webhooks => the events sent to the subscriptions' URLs, handlerPolkaWebhooks is the inbound side
+
+const (
+	// eventChirpDeleted is sent when an author deletes one of their chirps
+	eventChirpDeleted = "chirp.deleted"
+	// eventUserUpgraded is sent when a user gets Chirpy Red
+	eventUserUpgraded = "user.upgraded"
+
+	webhookStatusPending   = "pending"
+	webhookStatusSucceeded = "succeeded"
+	webhookStatusFailed    = "failed"
+
+	// maxWebhookSubscriptions caps the subscriptions of a user
+	maxWebhookSubscriptions = 10
+	// webhookMaxAttempts is the number of attempts before a delivery is marked as failed
+	webhookMaxAttempts = 8
+	// webhookBatchSize is the number of deliveries a replica claims at once, they're sent concurrently
+	webhookBatchSize = 50
+	// webhookBackoffBase & webhookBackoffMax => 1m, 2m, 4m, ... the attempts of a delivery span about 2 hours
+	webhookBackoffBase = time.Minute
+	webhookBackoffMax  = time.Hour
+)
+
+// webhookEvents are the events a subscription can ask for
+var webhookEvents = []string{eventChirpCreated, eventChirpDeleted, eventUserUpgraded}
+
+func webhookSubscriptionResponse(subscription database.WebhookSubscription) map[string]any {
+	return map[string]any{
+		"id":         subscription.ID,
+		"url":        subscription.Url,
+		"events":     subscription.Events,
+		"all_users":  !subscription.UserID.Valid,
+		"created_at": subscription.CreatedAt,
+		"updated_at": subscription.UpdatedAt,
+	}
+}
+
+func webhookDeliveryResponse(delivery database.WebhookDelivery) map[string]any {
+	var lastStatusCode *int32
+	if delivery.LastStatusCode.Valid {
+		lastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	var deliveredAt *time.Time
+	if delivery.DeliveredAt.Valid {
+		deliveredAt = &delivery.DeliveredAt.Time
+	}
+	var nextAttemptAt *time.Time
+	if delivery.Status == webhookStatusPending {
+		nextAttemptAt = &delivery.NextAttemptAt
+	}
+
+	return map[string]any{
+		"id":               delivery.ID,
+		"event":            delivery.Event,
+		"payload":          delivery.Payload,
+		"status":           delivery.Status,
+		"attempts":         delivery.Attempts,
+		"next_attempt_at":  nextAttemptAt,
+		"last_status_code": lastStatusCode,
+		"last_response":    delivery.LastResponse,
+		"last_error":       delivery.LastError,
+		"delivered_at":     deliveredAt,
+		"created_at":       delivery.CreatedAt,
+	}
+}
+
+// createWebhookDeliveries wraps the data in the event envelope, one delivery per subscription to the event.
+// Given the queries of the transaction making the change, the deliveries only exist if the change is committed,
+// the worker then sends them.
+func createWebhookDeliveries(ctx context.Context, q *database.Queries, event string, userID uuid.UUID, data any) error {
+	createdAt := time.Now()
+	payload, err := json.Marshal(map[string]any{
+		"id":         uuid.New(),
+		"event":      event,
+		"created_at": createdAt,
+		"data":       data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreateWebhookDeliveries(ctx, database.CreateWebhookDeliveriesParams{
+		Event:     event,
+		Payload:   payload,
+		CreatedAt: createdAt,
+		UserID:    userID,
+	})
+}
+
+// userWebhook loads the {webhookID} subscription of the user, writing the error response itself.
+// The subscriptions to every user belong to the admins.
+func (cfg *apiConfig) userWebhook(rw http.ResponseWriter, r *http.Request) (database.WebhookSubscription, bool) {
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return database.WebhookSubscription{}, false
+	}
+
+	webhookUUID, err := validateUUID(r.PathValue("webhookID"), "webhook ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return database.WebhookSubscription{}, false
+	}
+
+	subscription, err := cfg.db.GetWebhookSubscription(r.Context(), webhookUUID)
+	if err != nil {
+		writeErrorResponse(rw, 404, "webhook not found")
+		return database.WebhookSubscription{}, false
+	}
+
+	owned := subscription.UserID.Valid && subscription.UserID.UUID == user.ID
+	global := !subscription.UserID.Valid && auth.Role(user.Role).Satisfies(auth.RoleAdmin)
+	if !owned && !global {
+		writeErrorResponse(rw, 404, "webhook not found")
+		return database.WebhookSubscription{}, false
+	}
+
+	return subscription, true
+}
+
+// handlerCreateWebhook registers a subscription, its signing secret is only returned here
+func (cfg *apiConfig) handlerCreateWebhook(rw http.ResponseWriter, r *http.Request) {
+	type WebhookReq struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		// [Optional] admins only => the events of every user, for integrations
+		AllUsers bool `json:"all_users"`
+	}
+
+	var webhookReq WebhookReq
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&webhookReq)
+	if err != nil {
+		writeErrorResponse(rw, 400, "invalid request")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := webhooks.ValidateURL(webhookReq.URL); err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	if len(webhookReq.Events) == 0 {
+		writeErrorResponse(rw, 400, "missing required field: events")
+		return
+	}
+	slices.Sort(webhookReq.Events)
+	webhookReq.Events = slices.Compact(webhookReq.Events)
+	for _, event := range webhookReq.Events {
+		if !slices.Contains(webhookEvents, event) {
+			writeErrorResponse(rw, 400, "unknown event "+event)
+			return
+		}
+	}
+
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	ownerID := uuid.NullUUID{UUID: user.ID, Valid: true}
+	if webhookReq.AllUsers {
+		if !auth.Role(user.Role).Satisfies(auth.RoleAdmin) {
+			writeErrorResponse(rw, 403, "only admins can subscribe to the events of every user")
+			return
+		}
+		ownerID = uuid.NullUUID{}
+	} else {
+		subscriptions, err := cfg.db.ListWebhookSubscriptions(r.Context(), ownerID)
+		if err != nil {
+			writeErrorResponse(rw, 500, "couldn't create the webhook")
+			return
+		}
+		if len(subscriptions) >= maxWebhookSubscriptions {
+			writeErrorResponse(rw, 409, "you can't have more than 10 webhooks")
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't create the webhook")
+		return
+	}
+
+	subscription, err := cfg.db.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		ID:        uuid.New(),
+		UserID:    ownerID,
+		Url:       webhookReq.URL,
+		Secret:    "whsec_" + secret,
+		Events:    webhookReq.Events,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't create the webhook")
+		return
+	}
+
+	response := webhookSubscriptionResponse(subscription)
+	response["secret"] = subscription.Secret
+	writeSuccessResponse(rw, 201, response)
+}
+
+// handlerListWebhooks lists the user's subscriptions, and the ones to every user for the admins
+func (cfg *apiConfig) handlerListWebhooks(rw http.ResponseWriter, r *http.Request) {
+	user, err := cfg.authenticateUser(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	subscriptions, err := cfg.db.ListWebhookSubscriptions(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the webhooks")
+		return
+	}
+
+	if auth.Role(user.Role).Satisfies(auth.RoleAdmin) {
+		global, err := cfg.db.ListGlobalWebhookSubscriptions(r.Context())
+		if err != nil {
+			writeErrorResponse(rw, 500, "couldn't fetch the webhooks")
+			return
+		}
+		subscriptions = append(subscriptions, global...)
+	}
+
+	subscriptionsResponseJson := make([]map[string]any, len(subscriptions))
+	for i, subscription := range subscriptions {
+		subscriptionsResponseJson[i] = webhookSubscriptionResponse(subscription)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"webhooks": subscriptionsResponseJson,
+	})
+}
+
+// handlerDeleteWebhook removes the subscription along with its delivery log
+func (cfg *apiConfig) handlerDeleteWebhook(rw http.ResponseWriter, r *http.Request) {
+	subscription, ok := cfg.userWebhook(rw, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.DeleteWebhookSubscription(r.Context(), subscription.ID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't delete the webhook")
+		return
+	}
+
+	writeEmptyResponse(rw, 204)
+}
+
+// handlerListWebhookDeliveries returns the delivery log of the subscription, newest first
+func (cfg *apiConfig) handlerListWebhookDeliveries(rw http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r, 20, 100)
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	subscription, ok := cfg.userWebhook(rw, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Limit:          limit,
+		Offset:         offset,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't fetch the deliveries")
+		return
+	}
+
+	total, err := cfg.db.CountWebhookDeliveries(r.Context(), subscription.ID)
+	if err != nil {
+		writeErrorResponse(rw, 500, "couldn't count the deliveries")
+		return
+	}
+
+	deliveriesResponseJson := make([]map[string]any, len(deliveries))
+	for i, delivery := range deliveries {
+		deliveriesResponseJson[i] = webhookDeliveryResponse(delivery)
+	}
+
+	writeSuccessResponse(rw, 200, map[string]any{
+		"deliveries": deliveriesResponseJson,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// handlerRedeliverWebhook sends a delivery again, whatever its status, with a fresh set of attempts
+func (cfg *apiConfig) handlerRedeliverWebhook(rw http.ResponseWriter, r *http.Request) {
+	subscription, ok := cfg.userWebhook(rw, r)
+	if !ok {
+		return
+	}
+
+	deliveryUUID, err := validateUUID(r.PathValue("deliveryID"), "delivery ID")
+	if err != nil {
+		writeErrorResponse(rw, 400, err.Error())
+		return
+	}
+
+	delivery, err := cfg.db.RedeliverWebhook(r.Context(), database.RedeliverWebhookParams{
+		Now:            time.Now(),
+		ID:             deliveryUUID,
+		SubscriptionID: subscription.ID,
+	})
+	if err != nil {
+		writeErrorResponse(rw, 404, "delivery not found")
+		return
+	}
+
+	writeSuccessResponse(rw, 202, webhookDeliveryResponse(delivery))
+}
+
+// deliverWebhook makes one attempt and records its outcome, scheduling the next attempt when it failed
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.WebhookDelivery) error {
+	subscription, err := cfg.db.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	response, err := cfg.webhookSender.Send(ctx, webhooks.Request{
+		URL:        subscription.Url,
+		Secret:     []byte(subscription.Secret),
+		Event:      delivery.Event,
+		DeliveryID: delivery.ID.String(),
+		Body:       delivery.Payload,
+	})
+
+	now := time.Now()
+	attempt := database.RecordWebhookAttemptParams{
+		Status:       webhookStatusPending,
+		LastResponse: response.Body,
+		UpdatedAt:    now,
+		ID:           delivery.ID,
+	}
+	if err != nil {
+		attempt.LastError = err.Error()
+	} else {
+		attempt.LastStatusCode = sql.NullInt32{Int32: int32(response.StatusCode), Valid: true}
+	}
+
+	attempts := int(delivery.Attempts) + 1
+	switch {
+	case err == nil && response.Succeeded():
+		attempt.Status = webhookStatusSucceeded
+		attempt.NextAttemptAt = now
+		attempt.DeliveredAt = sql.NullTime{Time: now, Valid: true}
+	case attempts >= webhookMaxAttempts:
+		attempt.Status = webhookStatusFailed
+		attempt.NextAttemptAt = now
+	default:
+		attempt.NextAttemptAt = now.Add(webhooks.Backoff(attempts, webhookBackoffBase, webhookBackoffMax))
+	}
+
+	return cfg.db.RecordWebhookAttempt(ctx, attempt)
+}
+
+// deliverDueWebhooks sends the deliveries whose next attempt is due
+func (cfg *apiConfig) deliverDueWebhooks(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		now := time.Now()
+		deliveries, err := cfg.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+			// long enough for the whole batch to be sent, they're sent concurrently
+			LeaseUntil: now.Add(cfg.webhookTimeout + time.Minute),
+			Now:        now,
+			BatchSize:  webhookBatchSize,
+		})
+		if err != nil {
+			return sent, err
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := cfg.deliverWebhook(ctx, delivery); err != nil {
+					log.Printf("couldn't deliver webhook %s: %v", delivery.ID, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		sent += len(deliveries)
+		if len(deliveries) < webhookBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// runWebhookDeliverer runs deliverDueWebhooks every interval until the context is cancelled,
+// and purges the finished deliveries older than the retention every hour
+func (cfg *apiConfig) runWebhookDeliverer(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cfg.deliverDueWebhooks(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("couldn't deliver the webhooks: %v", err)
+			}
+		case <-purge.C:
+			purged, err := cfg.db.DeleteWebhookDeliveriesBefore(ctx, time.Now().Add(-retention))
+			if err != nil && ctx.Err() == nil {
+				log.Printf("couldn't purge the webhook deliveries: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("purged %d webhook deliveries", purged)
+			}
+		}
+	}
+}
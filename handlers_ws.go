@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/events"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+)
+
+// The WebSocket API pushes the same events as GET /api/stream, filtered by the topics the client subscribed to:
+//   - "timeline" => chirp.created from the users you follow
+//   - "conversation:<id>" => message.created & typing in one of your conversations
+//   - "notifications" => notification.created
+//
+// Each connection has a reader goroutine (client messages, pong frames) and a writer loop (events, replies, pings),
+// only the writer loop writes to the connection.
+
+const (
+	// wsReadLimit caps a client message, they're all small JSON commands
+	wsReadLimit = 4096
+	// wsMaxTopics caps the topics a single connection subscribes to
+	wsMaxTopics = 50
+	// wsOutboundBuffer is the number of replies waiting to be written before the client is considered stuck
+	wsOutboundBuffer = 16
+	// wsWriteTimeout bounds every write, a client not reading is disconnected
+	wsWriteTimeout = time.Second * 10
+	// wsTypingInterval throttles the typing indicators a connection sends per conversation
+	wsTypingInterval = time.Second * 3
+
+	wsTopicTimeline           = "timeline"
+	wsTopicNotifications      = "notifications"
+	wsTopicConversationPrefix = "conversation:"
+)
+
+// wsLimiter caps the open WebSocket connections, in total and per user
+type wsLimiter struct {
+	mu         sync.Mutex
+	total      int
+	perUser    map[uuid.UUID]int
+	maxTotal   int
+	maxPerUser int
+}
+
+func newWSLimiter(maxTotal, maxPerUser int) *wsLimiter {
+	return &wsLimiter{
+		perUser:    map[uuid.UUID]int{},
+		maxTotal:   maxTotal,
+		maxPerUser: maxPerUser,
+	}
+}
+
+// acquire reserves a connection for the user, release must follow when it returned true
+func (l *wsLimiter) acquire(userID uuid.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.total >= l.maxTotal || l.perUser[userID] >= l.maxPerUser {
+		return false
+	}
+	l.total++
+	l.perUser[userID]++
+	return true
+}
+
+func (l *wsLimiter) release(userID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	l.perUser[userID]--
+	if l.perUser[userID] <= 0 {
+		delete(l.perUser, userID)
+	}
+}
+
+// wsClientMessage is a command sent by the client
+type wsClientMessage struct {
+	// subscribe | unsubscribe | typing
+	Type           string `json:"type"`
+	Topic          string `json:"topic"`
+	ConversationID string `json:"conversation_id"`
+}
+
+// wsServerMessage is a reply or an event pushed to the client
+type wsServerMessage struct {
+	// subscribed | unsubscribed | event | error
+	Type    string          `json:"type"`
+	Topic   string          `json:"topic,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	ID      int64           `json:"id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// wsSession is the state of one connection shared by its reader & writer
+type wsSession struct {
+	userID uuid.UUID
+
+	mu     sync.Mutex
+	topics map[string]bool
+
+	outbound chan wsServerMessage
+	// cancels the connection, when the client is stuck or gone
+	cancel context.CancelFunc
+
+	// only used by the reader goroutine
+	lastTyping map[uuid.UUID]time.Time
+	// the last typing indicator for a conversation that isn't the user's, they're throttled too
+	typingRejectedAt time.Time
+}
+
+// send queues a reply for the writer loop, a client that lets them pile up is disconnected
+func (s *wsSession) send(message wsServerMessage) {
+	select {
+	case s.outbound <- message:
+	default:
+		s.cancel()
+	}
+}
+
+func (s *wsSession) subscribed(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.topics[topic]
+}
+
+// eventTopic returns the topic an event is pushed on
+func eventTopic(event events.Event) string {
+	switch event.Type {
+	case eventChirpCreated:
+		return wsTopicTimeline
+	case eventNotificationCreated:
+		return wsTopicNotifications
+	case eventMessageCreated, eventTyping:
+		var payload struct {
+			ConversationID string `json:"conversation_id"`
+		}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return ""
+		}
+		return wsTopicConversationPrefix + payload.ConversationID
+	}
+	return ""
+}
+
+func (cfg *apiConfig) handlerWebSocket(rw http.ResponseWriter, r *http.Request) {
+	userUUID, err := cfg.validateJWTFromRequest(r)
+	if err != nil {
+		writeErrorResponse(rw, 401, err.Error())
+		return
+	}
+
+	if !cfg.wsLimiter.acquire(userUUID) {
+		writeErrorResponse(rw, 429, "too many open connections")
+		return
+	}
+	defer cfg.wsLimiter.release(userUUID)
+
+	conn, err := websocket.Accept(rw, r, nil)
+	if err != nil {
+		// Accept already wrote the error response
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// events are received from the start, the topics decide which ones are written
+	sub := cfg.events.Subscribe(userUUID)
+	defer sub.Close()
+
+	session := &wsSession{
+		userID:     userUUID,
+		topics:     map[string]bool{},
+		outbound:   make(chan wsServerMessage, wsOutboundBuffer),
+		cancel:     cancel,
+		lastTyping: map[uuid.UUID]time.Time{},
+	}
+
+	go cfg.readWebSocket(ctx, conn, r, session)
+
+	muted := cfg.newMutedWordsMatcher(userUUID)
+
+	ping := time.NewTicker(cfg.wsPingInterval)
+	defer ping.Stop()
+
+	write := func(message wsServerMessage) error {
+		writeCtx, cancelWrite := context.WithTimeout(ctx, wsWriteTimeout)
+		defer cancelWrite()
+		return wsjson.Write(writeCtx, conn, message)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			// the reader goroutine reads the pong, a client that doesn't answer is gone
+			pingCtx, cancelPing := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				return
+			}
+		case message := <-session.outbound:
+			if err := write(message); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			// closed when the client fell behind or the server shuts down, it can reconnect & catch up through the REST API
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "reconnect")
+				return
+			}
+
+			topic := eventTopic(event)
+			if topic == "" || !session.subscribed(topic) || muted.mutes(ctx, event) {
+				continue
+			}
+
+			err := write(wsServerMessage{
+				Type:  "event",
+				Topic: topic,
+				Event: event.Type,
+				ID:    event.ID,
+				Data:  event.Payload,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readWebSocket handles the client messages until the connection fails, then cancels the session
+func (cfg *apiConfig) readWebSocket(ctx context.Context, conn *websocket.Conn, r *http.Request, session *wsSession) {
+	defer session.cancel()
+
+	for {
+		var message wsClientMessage
+		if err := wsjson.Read(ctx, conn, &message); err != nil {
+			return
+		}
+
+		switch message.Type {
+		case "subscribe":
+			cfg.wsSubscribe(r, session, message.Topic)
+		case "unsubscribe":
+			session.mu.Lock()
+			delete(session.topics, message.Topic)
+			session.mu.Unlock()
+			session.send(wsServerMessage{Type: "unsubscribed", Topic: message.Topic})
+		case "typing":
+			cfg.wsTyping(r, session, message.ConversationID)
+		default:
+			session.send(wsServerMessage{Type: "error", Message: fmt.Sprintf("unknown message type %q", message.Type)})
+		}
+	}
+}
+
+func (cfg *apiConfig) wsSubscribe(r *http.Request, session *wsSession, topic string) {
+	switch {
+	case topic == wsTopicTimeline, topic == wsTopicNotifications:
+	case strings.HasPrefix(topic, wsTopicConversationPrefix):
+		// only the participants can follow a conversation
+		conversationUUID, err := uuid.Parse(strings.TrimPrefix(topic, wsTopicConversationPrefix))
+		if err != nil {
+			session.send(wsServerMessage{Type: "error", Topic: topic, Message: "invalid conversation ID"})
+			return
+		}
+		_, err = cfg.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+			ID:     conversationUUID,
+			UserID: session.userID,
+		})
+		if err != nil {
+			session.send(wsServerMessage{Type: "error", Topic: topic, Message: "conversation not found"})
+			return
+		}
+		// normalized so it matches the topic of the events
+		topic = wsTopicConversationPrefix + conversationUUID.String()
+	case strings.HasPrefix(topic, "tag:"), strings.HasPrefix(topic, "chirp:"):
+		// chirps have no tags or replies yet, there's nothing to push on these
+		session.send(wsServerMessage{Type: "error", Topic: topic, Message: "tag and thread topics aren't supported"})
+		return
+	default:
+		session.send(wsServerMessage{Type: "error", Topic: topic, Message: "unknown topic"})
+		return
+	}
+
+	session.mu.Lock()
+	full := len(session.topics) >= wsMaxTopics && !session.topics[topic]
+	if !full {
+		session.topics[topic] = true
+	}
+	session.mu.Unlock()
+
+	if full {
+		session.send(wsServerMessage{Type: "error", Topic: topic, Message: fmt.Sprintf("a connection can subscribe to at most %d topics", wsMaxTopics)})
+		return
+	}
+	session.send(wsServerMessage{Type: "subscribed", Topic: topic})
+}
+
+// wsTyping sends a typing indicator to the other participant, through every replica without storing it
+func (cfg *apiConfig) wsTyping(r *http.Request, session *wsSession, conversationID string) {
+	conversationUUID, err := uuid.Parse(conversationID)
+	if err != nil {
+		session.send(wsServerMessage{Type: "error", Message: "invalid conversation ID"})
+		return
+	}
+
+	// throttled silently, clients send it on every key stroke.
+	// Unknown conversations are throttled as a whole, so random IDs don't each cost a query
+	now := time.Now()
+	if now.Sub(session.lastTyping[conversationUUID]) < wsTypingInterval || now.Sub(session.typingRejectedAt) < wsTypingInterval {
+		return
+	}
+
+	conversation, err := cfg.db.GetConversationForUser(r.Context(), database.GetConversationForUserParams{
+		ID:     conversationUUID,
+		UserID: session.userID,
+	})
+	if err != nil {
+		session.typingRejectedAt = now
+		session.send(wsServerMessage{Type: "error", Message: "conversation not found"})
+		return
+	}
+
+	// only the conversations still within their interval are kept, the map stays small
+	for id, typedAt := range session.lastTyping {
+		if now.Sub(typedAt) >= wsTypingInterval {
+			delete(session.lastTyping, id)
+		}
+	}
+	session.lastTyping[conversation.ID] = now
+
+	// a block closes the conversation, typing included
+	recipientUUID := otherParticipant(conversation, session.userID)
+	blocked, err := cfg.eitherBlocked(r, session.userID, recipientUUID)
+	if err != nil || blocked {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"conversation_id": conversation.ID,
+		"user_id":         session.userID,
+	})
+	if err != nil {
+		return
+	}
+
+	notification, err := events.EncodeEphemeral(events.Event{
+		UserID:    recipientUUID,
+		Type:      eventTyping,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("couldn't encode the typing indicator: %v", err)
+		return
+	}
+
+	if err := cfg.db.NotifyEphemeralEvent(r.Context(), notification); err != nil {
+		log.Printf("couldn't send the typing indicator: %v", err)
+	}
+}
@@ -10,26 +10,16 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// HashPassword hashes with the default password manager (argon2id)
 func HashPassword(password string) (string, error) {
-	passByteSli := []byte(password)
-	hashByteSli, err := bcrypt.GenerateFromPassword(passByteSli, 10)
-	if err != nil {
-		return "", fmt.Errorf("couldn't hash the password: %v", err)
-	}
-	hashedPassStr := string(hashByteSli)
-
-	return hashedPassStr, nil
+	return defaultPasswordManager.Hash(password)
 }
 
+// ComparePasswordHash verifies against hashes of every supported algorithm (argon2id, bcrypt)
 func ComparePasswordHash(password, hash string) error {
-	hashByteSli := []byte(hash)
-	passByteSli := []byte(password)
-
-	err := bcrypt.CompareHashAndPassword(hashByteSli, passByteSli)
-	if err != nil {
+	if err := defaultPasswordManager.Verify(password, hash); err != nil {
 		return fmt.Errorf("password is not correct")
 	}
 
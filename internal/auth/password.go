@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPasswordMismatch = errors.New("password is not correct")
+	ErrUnknownHash      = errors.New("unrecognized password hash format")
+	// ErrPasswordBusy is returned when every hashing slot stayed taken, see LimitConcurrency
+	ErrPasswordBusy = errors.New("too many passwords being checked, try again later")
+)
+
+// PasswordHasher hashes passwords into self-describing encoded strings (PHC / modular crypt format),
+// so the algorithm and its parameters can always be read back from the stored hash.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify returns ErrPasswordMismatch when the password doesn't match the encoded hash
+	Verify(password, encodedHash string) error
+	// Identifies reports whether the encoded hash was produced by this hasher's algorithm
+	Identifies(encodedHash string) bool
+	// NeedsRehash reports whether the encoded hash was made with parameters other than the current ones
+	NeedsRehash(encodedHash string) bool
+}
+
+// ---------------------------- bcrypt ----------------------------
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashByteSli, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", fmt.Errorf("couldn't hash the password: %v", err)
+	}
+
+	return string(hashByteSli), nil
+}
+
+func (h BcryptHasher) Verify(password, encodedHash string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrPasswordMismatch
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't verify the password: %v", err)
+	}
+
+	return nil
+}
+
+func (h BcryptHasher) Identifies(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+func (h BcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != h.Cost
+}
+
+// ---------------------------- argon2id ----------------------------
+
+type Argon2idHasher struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idHasher follows the second recommended option of RFC 9106 (64 MiB, 3 passes).
+func DefaultArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("couldn't generate a salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Memory,
+		h.Iterations,
+		h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(password, encodedHash string) error {
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return err
+	}
+
+	// the stored parameters are used, not the current ones, so older hashes keep verifying
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.iterations, decoded.memory, decoded.parallelism, uint32(len(decoded.key)))
+	if subtle.ConstantTimeCompare(key, decoded.key) != 1 {
+		return ErrPasswordMismatch
+	}
+
+	return nil
+}
+
+func (h Argon2idHasher) Identifies(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func (h Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	decoded, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return decoded.version != argon2.Version ||
+		decoded.memory != h.Memory ||
+		decoded.iterations != h.Iterations ||
+		decoded.parallelism != h.Parallelism ||
+		uint32(len(decoded.salt)) != h.SaltLength ||
+		uint32(len(decoded.key)) != h.KeyLength
+}
+
+func decodeArgon2idHash(encodedHash string) (argon2idHash, error) {
+	var decoded argon2idHash
+
+	// "", "argon2id", "v=19", "m=65536,t=3,p=4", "<salt>", "<key>"
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return decoded, ErrUnknownHash
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &decoded.version); err != nil {
+		return decoded, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.memory, &decoded.iterations, &decoded.parallelism); err != nil {
+		return decoded, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return decoded, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	decoded.salt = salt
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return decoded, fmt.Errorf("invalid argon2id key: %v", err)
+	}
+	decoded.key = key
+
+	return decoded, nil
+}
+
+// ---------------------------- manager ----------------------------
+
+// PasswordManager hashes new passwords with the preferred hasher, while still verifying
+// hashes made by any of the legacy ones, so stored hashes can be upgraded on the next login.
+type PasswordManager struct {
+	preferred PasswordHasher
+	hashers   []PasswordHasher
+
+	// compared against when there is no real hash to check, see SimulateVerify
+	dummyHash func() string
+
+	// bounds the hashes running at once, nil for no limit, see LimitConcurrency
+	slots    chan struct{}
+	slotWait time.Duration
+}
+
+func NewPasswordManager(preferred PasswordHasher, legacy ...PasswordHasher) *PasswordManager {
+	manager := &PasswordManager{
+		preferred: preferred,
+		hashers:   append([]PasswordHasher{preferred}, legacy...),
+	}
+	manager.dummyHash = sync.OnceValue(func() string {
+		hash, err := preferred.Hash("chirpy-dummy-password")
+		if err != nil {
+			return ""
+		}
+		return hash
+	})
+
+	return manager
+}
+
+// LimitConcurrency caps the hashes & verifications running at once to n, each one holds the memory of its
+// algorithm (64 MiB for the default argon2id) so a burst of logins could run the process out of memory.
+// A call waits up to wait for a slot, then fails with ErrPasswordBusy. It's meant to be set before any use.
+func (m *PasswordManager) LimitConcurrency(n int, wait time.Duration) {
+	m.slots = make(chan struct{}, max(n, 1))
+	m.slotWait = wait
+}
+
+// acquire takes a hashing slot, the returned func gives it back
+func (m *PasswordManager) acquire() (func(), error) {
+	if m.slots == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-m.slots }
+	select {
+	case m.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	timer := time.NewTimer(m.slotWait)
+	defer timer.Stop()
+	select {
+	case m.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrPasswordBusy
+	}
+}
+
+func (m *PasswordManager) Hash(password string) (string, error) {
+	release, err := m.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return m.preferred.Hash(password)
+}
+
+func (m *PasswordManager) Verify(password, encodedHash string) error {
+	release, err := m.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return m.verify(password, encodedHash)
+}
+
+func (m *PasswordManager) verify(password, encodedHash string) error {
+	for _, hasher := range m.hashers {
+		if hasher.Identifies(encodedHash) {
+			return hasher.Verify(password, encodedHash)
+		}
+	}
+
+	return ErrUnknownHash
+}
+
+func (m *PasswordManager) Identifies(encodedHash string) bool {
+	for _, hasher := range m.hashers {
+		if hasher.Identifies(encodedHash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NeedsRehash is true for hashes of any non-preferred algorithm, or of the preferred one with outdated parameters
+func (m *PasswordManager) NeedsRehash(encodedHash string) bool {
+	if !m.preferred.Identifies(encodedHash) {
+		return true
+	}
+
+	return m.preferred.NeedsRehash(encodedHash)
+}
+
+// SimulateVerify burns the same amount of work as Verify, the result is always discarded.
+// It is meant for logins of unknown accounts, so they can't be told apart by timing.
+// It takes a slot like Verify, only ErrPasswordBusy is returned.
+func (m *PasswordManager) SimulateVerify(password string) error {
+	release, err := m.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	_ = m.verify(password, m.dummyHash())
+	return nil
+}
+
+var defaultPasswordManager = NewPasswordManager(DefaultArgon2idHasher(), BcryptHasher{Cost: 10})
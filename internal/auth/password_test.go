@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cheap parameters so the tests stay fast
+func testArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{
+		Memory:      8 * 1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	hasher := testArgon2idHasher()
+
+	hash, err := hasher.Hash("P@$$W0rD")
+	if err != nil {
+		t.Fatalf("couldn't hash the password: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$") {
+		t.Errorf("unexpected encoded hash: %s", hash)
+	}
+
+	if err := hasher.Verify("P@$$W0rD", hash); err != nil {
+		t.Errorf("expected the password to match: %v", err)
+	}
+
+	if err := hasher.Verify("wrong password", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected ErrPasswordMismatch, got %v", err)
+	}
+
+	if hasher.NeedsRehash(hash) {
+		t.Error("a hash made with the current parameters shouldn't need a rehash")
+	}
+
+	stronger := hasher
+	stronger.Iterations = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Error("a hash made with fewer iterations should need a rehash")
+	}
+
+	// older parameters still verify
+	if err := stronger.Verify("P@$$W0rD", hash); err != nil {
+		t.Errorf("expected the old hash to still verify: %v", err)
+	}
+}
+
+func TestPasswordManagerUpgradesBcrypt(t *testing.T) {
+	bcryptHasher := BcryptHasher{Cost: 4}
+	manager := NewPasswordManager(testArgon2idHasher(), bcryptHasher)
+
+	legacyHash, err := bcryptHasher.Hash("Hello World as a password")
+	if err != nil {
+		t.Fatalf("couldn't hash the password: %v", err)
+	}
+
+	if err := manager.Verify("Hello World as a password", legacyHash); err != nil {
+		t.Errorf("expected the bcrypt hash to verify: %v", err)
+	}
+
+	if !manager.NeedsRehash(legacyHash) {
+		t.Error("a bcrypt hash should need a rehash when argon2id is preferred")
+	}
+
+	newHash, err := manager.Hash("Hello World as a password")
+	if err != nil {
+		t.Fatalf("couldn't hash the password: %v", err)
+	}
+
+	if manager.NeedsRehash(newHash) {
+		t.Error("a hash from the preferred hasher shouldn't need a rehash")
+	}
+
+	if err := manager.Verify("Hello World as a password", "$md5$whatever"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("expected ErrUnknownHash, got %v", err)
+	}
+}
+
+func TestBcryptNeedsRehashOnCostChange(t *testing.T) {
+	hash, err := BcryptHasher{Cost: 4}.Hash("123456789")
+	if err != nil {
+		t.Fatalf("couldn't hash the password: %v", err)
+	}
+
+	if (BcryptHasher{Cost: 4}).NeedsRehash(hash) {
+		t.Error("same cost shouldn't need a rehash")
+	}
+
+	if !(BcryptHasher{Cost: 5}).NeedsRehash(hash) {
+		t.Error("a different cost should need a rehash")
+	}
+}
+
+// blockingHasher holds its slot until released, to fill the manager's slots
+type blockingHasher struct {
+	Argon2idHasher
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h blockingHasher) Hash(password string) (string, error) {
+	h.started <- struct{}{}
+	<-h.release
+	return "$argon2id$blocked", nil
+}
+
+func TestPasswordManagerLimitsConcurrency(t *testing.T) {
+	hasher := blockingHasher{
+		Argon2idHasher: testArgon2idHasher(),
+		started:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	manager := NewPasswordManager(hasher)
+	manager.LimitConcurrency(1, time.Millisecond*20)
+
+	done := make(chan error)
+	go func() {
+		_, err := manager.Hash("123456789")
+		done <- err
+	}()
+	<-hasher.started
+
+	// the only slot is taken
+	if _, err := manager.Hash("123456789"); !errors.Is(err, ErrPasswordBusy) {
+		t.Errorf("expected ErrPasswordBusy from Hash, got %v", err)
+	}
+	if err := manager.Verify("123456789", "$argon2id$whatever"); !errors.Is(err, ErrPasswordBusy) {
+		t.Errorf("expected ErrPasswordBusy from Verify, got %v", err)
+	}
+	if err := manager.SimulateVerify("123456789"); !errors.Is(err, ErrPasswordBusy) {
+		t.Errorf("expected ErrPasswordBusy from SimulateVerify, got %v", err)
+	}
+
+	close(hasher.release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the slot is given back
+	if err := manager.Verify("123456789", "$md5$whatever"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("expected the slot to be free again, got %v", err)
+	}
+}
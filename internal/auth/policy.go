@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// PolicyViolation is one reason a password was rejected, Code is stable and meant for clients
+type PolicyViolation struct {
+	Code    string
+	Message string
+}
+
+// BreachChecker tells whether a password is known from a data breach
+type BreachChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+type PasswordPolicy struct {
+	// MinLength is counted in characters, not bytes
+	MinLength int
+	// MaxBytes guards against bcrypt silently ignoring everything past 72 bytes
+	MaxBytes int
+	// Breached is optional, nil disables the breached-password check
+	Breached BreachChecker
+}
+
+// Validate returns every rule the password breaks, an empty slice means the password is acceptable
+func (p PasswordPolicy) Validate(password string) ([]PolicyViolation, error) {
+	violations := []PolicyViolation{}
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Code:    "too_short",
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, PolicyViolation{
+			Code:    "too_long",
+			Message: fmt.Sprintf("password must be at most %d bytes long", p.MaxBytes),
+		})
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.IsBreached(password)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't check the password against the breach corpus: %w", err)
+		}
+		if breached {
+			violations = append(violations, PolicyViolation{
+				Code:    "breached",
+				Message: "password has appeared in a data breach, please choose another one",
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// BreachCorpus is a local copy of a breached-password list (e.g. the Pwned Passwords dump).
+// Lookups follow the k-anonymity model of the Pwned Passwords range API: a password is
+// SHA-1 hashed, the first 5 hex chars pick a range and only the suffixes of that range are compared.
+//
+// The corpus is either a single file of "SHA1[:COUNT]" lines loaded into memory, or a directory of
+// per-prefix range files ("<PREFIX>" or "<PREFIX>.txt" holding "SUFFIX[:COUNT]" lines) read on demand,
+// which is the layout to use for the full dump.
+type BreachCorpus struct {
+	dir    string
+	ranges map[string]map[string]struct{}
+}
+
+const breachPrefixLength = 5
+
+// LoadBreachCorpus opens a corpus file or directory, see BreachCorpus for the expected layout
+func LoadBreachCorpus(path string) (*BreachCorpus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the breach corpus: %w", err)
+	}
+
+	if info.IsDir() {
+		return &BreachCorpus{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the breach corpus: %w", err)
+	}
+	defer file.Close()
+
+	return ParseBreachCorpus(file)
+}
+
+// ParseBreachCorpus reads "SHA1[:COUNT]" lines into an in-memory corpus
+func ParseBreachCorpus(r io.Reader) (*BreachCorpus, error) {
+	corpus := &BreachCorpus{ranges: map[string]map[string]struct{}{}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		hash, ok := parseBreachLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("invalid SHA-1 in the breach corpus: %q", hash)
+		}
+
+		prefix, suffix := hash[:breachPrefixLength], hash[breachPrefixLength:]
+		if corpus.ranges[prefix] == nil {
+			corpus.ranges[prefix] = map[string]struct{}{}
+		}
+		corpus.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read the breach corpus: %w", err)
+	}
+
+	return corpus, nil
+}
+
+func (c *BreachCorpus) IsBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachPrefixLength], hash[breachPrefixLength:]
+
+	if c.dir == "" {
+		_, found := c.ranges[prefix][suffix]
+		return found, nil
+	}
+
+	return c.searchRangeFile(prefix, suffix)
+}
+
+func (c *BreachCorpus) searchRangeFile(prefix, suffix string) (bool, error) {
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		found, err := searchBreachFile(filepath.Join(c.dir, name), suffix)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return found, err
+	}
+
+	// no range file means no breached password shares this prefix
+	return false, nil
+}
+
+// searchBreachFile looks for the suffix in one range file, the file is closed before returning
+func searchBreachFile(path, suffix string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if candidate, ok := parseBreachLine(scanner.Text()); ok && candidate == suffix {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// parseBreachLine strips the optional ":COUNT" part, blank lines and # comments are skipped
+func parseBreachLine(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+
+	hash, _, _ := strings.Cut(line, ":")
+	return strings.ToUpper(hash), true
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SHA-1 of "password"
+const breachedPasswordHash = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func violationCodes(violations []PolicyViolation) []string {
+	codes := []string{}
+	for _, violation := range violations {
+		codes = append(codes, violation.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyLengths(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, MaxBytes: 72}
+
+	tests := map[string]string{
+		"Moaz":                            "too_short",
+		"P@$$W0rD":                        "",
+		"كلمةسرطويلة":                     "", // 11 characters, 22 bytes
+		strings.Repeat("a", 73):           "too_long",
+		strings.Repeat("ß", 40):           "too_long", // 40 characters, 80 bytes
+		"Hello World as a password":       "",
+		strings.Repeat("x", 72) + "extra": "too_long",
+	}
+
+	for password, expectedCode := range tests {
+		violations, err := policy.Validate(password)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		codes := violationCodes(violations)
+		if expectedCode == "" && len(codes) > 0 {
+			t.Errorf("%q: expected no violations, got %v", password, codes)
+		}
+		if expectedCode != "" && (len(codes) != 1 || codes[0] != expectedCode) {
+			t.Errorf("%q: expected [%s], got %v", password, expectedCode, codes)
+		}
+	}
+}
+
+func TestBreachCorpusFile(t *testing.T) {
+	corpus, err := ParseBreachCorpus(strings.NewReader("# pwned passwords\n" + strings.ToLower(breachedPasswordHash) + ":9659365\n\n"))
+	if err != nil {
+		t.Fatalf("couldn't parse the corpus: %v", err)
+	}
+
+	policy := PasswordPolicy{MinLength: 8, Breached: corpus}
+
+	violations, err := policy.Validate("password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if codes := violationCodes(violations); len(codes) != 1 || codes[0] != "breached" {
+		t.Errorf("expected [breached], got %v", codes)
+	}
+
+	violations, _ = policy.Validate("correct horse battery staple")
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, got %v", violationCodes(violations))
+	}
+
+	if _, err := ParseBreachCorpus(strings.NewReader("not-a-hash\n")); err == nil {
+		t.Error("expected an error for a malformed corpus")
+	}
+}
+
+func TestBreachCorpusRangeDirectory(t *testing.T) {
+	dir := t.TempDir()
+	rangeFile := breachedPasswordHash[:5] + ".txt"
+	rangeContent := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + breachedPasswordHash[5:] + ":9659365\n"
+	if err := os.WriteFile(filepath.Join(dir, rangeFile), []byte(rangeContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := LoadBreachCorpus(dir)
+	if err != nil {
+		t.Fatalf("couldn't load the corpus: %v", err)
+	}
+
+	if breached, err := corpus.IsBreached("password"); err != nil || !breached {
+		t.Errorf("expected \"password\" to be breached, got %v (err: %v)", breached, err)
+	}
+
+	if breached, err := corpus.IsBreached("correct horse battery staple"); err != nil || breached {
+		t.Errorf("expected a missing range to mean not breached, got %v (err: %v)", breached, err)
+	}
+}
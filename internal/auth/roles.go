@@ -0,0 +1,36 @@
+package auth
+
+import "fmt"
+
+// Role is what a user is allowed to do, every role includes the permissions of the ones below it
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ParseRole(role string) (Role, error) {
+	if _, ok := roleRanks[Role(role)]; !ok {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+
+	return Role(role), nil
+}
+
+// Satisfies reports whether the role is at least the required one, unknown roles satisfy nothing
+func (r Role) Satisfies(required Role) bool {
+	rank, ok := roleRanks[r]
+	if !ok {
+		return false
+	}
+
+	return rank >= roleRanks[required]
+}
@@ -0,0 +1,37 @@
+package auth
+
+import "testing"
+
+func TestRoleSatisfies(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		expected bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleUser, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleModerator, RoleModerator, true},
+		{RoleUser, RoleModerator, false},
+		{RoleUser, RoleUser, true},
+		{Role("superuser"), RoleUser, false},
+		{Role(""), RoleUser, false},
+	}
+
+	for _, test := range tests {
+		if got := test.role.Satisfies(test.required); got != test.expected {
+			t.Errorf("%q satisfies %q: expected %v, got %v", test.role, test.required, test.expected, got)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if role, err := ParseRole("moderator"); err != nil || role != RoleModerator {
+		t.Errorf("expected moderator, got %q (err: %v)", role, err)
+	}
+
+	if _, err := ParseRole("root"); err == nil {
+		t.Error("expected an error for an unknown role")
+	}
+}
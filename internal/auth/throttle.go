@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// ThrottleConfig controls how a LoginThrottle reacts to failed attempts.
+type ThrottleConfig struct {
+	// FreeAttempts is how many failures are tolerated before any delay is applied
+	FreeAttempts int
+	// BaseDelay is the first backoff delay, it doubles on every further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock the key out for LockoutDuration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// ResetAfter is how long a key has to stay quiet before its failures are forgotten
+	ResetAfter time.Duration
+}
+
+type failedAttempts struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	// inFlight is the number of attempts allowed but not settled yet by Fail, Release or Reset
+	inFlight   int
+	reservedAt time.Time
+}
+
+// stale reports whether the failures are old enough to be forgotten
+func (a *failedAttempts) stale(now time.Time, resetAfter time.Duration) bool {
+	return now.Sub(a.lastFailure) > resetAfter && !now.Before(a.blockedUntil)
+}
+
+// LoginThrottle tracks failed login attempts per key (an account, a client IP, ...)
+// and applies exponential backoff followed by a temporary lockout.
+//
+// Every attempt allowed by Allow is reserved until the caller settles it with Fail, Release or Reset,
+// so parallel guesses can't all slip through before the first failure is recorded.
+type LoginThrottle struct {
+	mu        sync.Mutex
+	cfg       ThrottleConfig
+	attempts  map[string]*failedAttempts
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewLoginThrottle(cfg ThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		cfg:      cfg,
+		attempts: map[string]*failedAttempts{},
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether a login attempt for the key may proceed, and if not,
+// how long the caller has to wait before trying again.
+// An allowed attempt is reserved and must be settled with Fail, Release or Reset.
+func (t *LoginThrottle) Allow(key string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	entry, ok := t.attempts[key]
+	if !ok {
+		entry = &failedAttempts{}
+		t.attempts[key] = entry
+	} else if entry.stale(now, t.cfg.ResetAfter) {
+		entry.count = 0
+		entry.blockedUntil = time.Time{}
+	}
+
+	if now.Before(entry.blockedUntil) {
+		return entry.blockedUntil.Sub(now), false
+	}
+
+	// the pending attempts count as failures until they're settled,
+	// past the free attempts only one attempt at a time is let through
+	if entry.inFlight > 0 && entry.count+entry.inFlight >= t.cfg.FreeAttempts {
+		return t.cfg.BaseDelay, false
+	}
+
+	entry.inFlight++
+	entry.reservedAt = now
+	return 0, true
+}
+
+// Fail records a failed attempt for the key, settling its reservation.
+func (t *LoginThrottle) Fail(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+
+	entry, ok := t.attempts[key]
+	if !ok {
+		entry = &failedAttempts{}
+		t.attempts[key] = entry
+	} else if entry.stale(now, t.cfg.ResetAfter) {
+		entry.count = 0
+		entry.blockedUntil = time.Time{}
+	}
+
+	entry.settle()
+	entry.count++
+	entry.lastFailure = now
+
+	// once locked out, every further failure renews the lockout
+	if entry.count >= t.cfg.LockoutAfter {
+		entry.blockedUntil = now.Add(t.cfg.LockoutDuration)
+		return
+	}
+
+	if entry.count > t.cfg.FreeAttempts {
+		entry.blockedUntil = now.Add(t.backoff(entry.count - t.cfg.FreeAttempts))
+	}
+}
+
+// Release settles a reserved attempt without recording a failure.
+func (t *LoginThrottle) Release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.attempts[key]
+	if !ok {
+		return
+	}
+	entry.settle()
+	if entry.inFlight == 0 && entry.count == 0 {
+		delete(t.attempts, key)
+	}
+}
+
+// Reset settles a reserved attempt and forgets every failure recorded for the key, e.g. after a successful login.
+func (t *LoginThrottle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.attempts[key]
+	if !ok {
+		return
+	}
+	entry.settle()
+	if entry.inFlight == 0 {
+		delete(t.attempts, key)
+		return
+	}
+	entry.count = 0
+	entry.blockedUntil = time.Time{}
+}
+
+func (a *failedAttempts) settle() {
+	if a.inFlight > 0 {
+		a.inFlight--
+	}
+}
+
+func (t *LoginThrottle) backoff(step int) time.Duration {
+	delay := t.cfg.BaseDelay
+	for i := 1; i < step; i++ {
+		delay *= 2
+		if delay >= t.cfg.MaxDelay {
+			return t.cfg.MaxDelay
+		}
+	}
+
+	return min(delay, t.cfg.MaxDelay)
+}
+
+// prune drops stale entries so the map doesn't grow forever, it runs at most once per ResetAfter
+func (t *LoginThrottle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < t.cfg.ResetAfter {
+		return
+	}
+	t.lastPrune = now
+
+	for key, entry := range t.attempts {
+		// a reservation never settled (a crashed request) doesn't keep the entry forever
+		abandoned := now.Sub(entry.reservedAt) > t.cfg.ResetAfter
+		if (entry.inFlight == 0 || abandoned) && entry.stale(now, t.cfg.ResetAfter) {
+			delete(t.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestThrottle(now *time.Time) *LoginThrottle {
+	throttle := NewLoginThrottle(ThrottleConfig{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Second * 4,
+		LockoutAfter:    6,
+		LockoutDuration: time.Minute * 10,
+		ResetAfter:      time.Hour,
+	})
+	throttle.now = func() time.Time { return *now }
+
+	return throttle
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	expectedWaits := []time.Duration{0, 0, time.Second, time.Second * 2, time.Second * 4}
+	for i, expectedWait := range expectedWaits {
+		throttle.Fail("moaz@example.com")
+
+		wait, allowed := throttle.Allow("moaz@example.com")
+		if expectedWait == 0 && !allowed {
+			t.Errorf("failure %d: expected to be allowed, got wait %v", i+1, wait)
+		}
+		if expectedWait > 0 && (allowed || wait != expectedWait) {
+			t.Errorf("failure %d: expected wait %v, got %v (allowed: %v)", i+1, expectedWait, wait, allowed)
+		}
+
+		now = now.Add(wait)
+	}
+
+	// other keys are not affected
+	if _, allowed := throttle.Allow("someone@example.com"); !allowed {
+		t.Error("an untouched key shouldn't be throttled")
+	}
+}
+
+func TestLoginThrottleLockoutAndReset(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	for range 6 {
+		throttle.Fail("10.0.0.1")
+	}
+
+	wait, allowed := throttle.Allow("10.0.0.1")
+	if allowed || wait != time.Minute*10 {
+		t.Fatalf("expected a 10m lockout, got wait %v (allowed: %v)", wait, allowed)
+	}
+
+	now = now.Add(time.Minute * 10)
+	if _, allowed := throttle.Allow("10.0.0.1"); !allowed {
+		t.Fatal("lockout should be over")
+	}
+
+	// still over the limit, the next failure locks again straight away
+	throttle.Fail("10.0.0.1")
+	if _, allowed := throttle.Allow("10.0.0.1"); allowed {
+		t.Fatal("expected the lockout to be renewed")
+	}
+
+	throttle.Reset("10.0.0.1")
+	if _, allowed := throttle.Allow("10.0.0.1"); !allowed {
+		t.Fatal("expected Reset to clear the lockout")
+	}
+}
+
+func TestLoginThrottleForgetsIdleFailures(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	for range 4 {
+		throttle.Fail("moaz@example.com")
+	}
+
+	now = now.Add(time.Hour * 2)
+	throttle.Fail("moaz@example.com")
+	if _, allowed := throttle.Allow("moaz@example.com"); !allowed {
+		t.Error("failures older than ResetAfter should have been forgotten")
+	}
+}
+
+func TestLoginThrottleReservesParallelAttempts(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	// the free attempts can run in parallel, the next one has to wait for them to settle
+	for i := range 2 {
+		if _, allowed := throttle.Allow("moaz@example.com"); !allowed {
+			t.Fatalf("attempt %d: expected to be allowed", i+1)
+		}
+	}
+	if _, allowed := throttle.Allow("moaz@example.com"); allowed {
+		t.Fatal("expected a third parallel attempt to be refused")
+	}
+
+	// a released attempt frees its slot without counting as a failure
+	throttle.Release("moaz@example.com")
+	if _, allowed := throttle.Allow("moaz@example.com"); !allowed {
+		t.Fatal("expected the released slot to be reusable")
+	}
+
+	throttle.Fail("moaz@example.com")
+	throttle.Fail("moaz@example.com")
+	if _, allowed := throttle.Allow("moaz@example.com"); !allowed {
+		t.Fatal("expected an attempt once the free ones settled")
+	}
+	throttle.Fail("moaz@example.com")
+
+	// past the free attempts, the backoff applies and only one attempt runs at a time
+	wait, allowed := throttle.Allow("moaz@example.com")
+	if allowed || wait != time.Second {
+		t.Fatalf("expected a 1s backoff, got wait %v (allowed: %v)", wait, allowed)
+	}
+	now = now.Add(wait)
+	if _, allowed := throttle.Allow("moaz@example.com"); !allowed {
+		t.Fatal("expected an attempt after the backoff")
+	}
+	if _, allowed := throttle.Allow("moaz@example.com"); allowed {
+		t.Fatal("expected a parallel attempt during the backoff to be refused")
+	}
+
+	throttle.Reset("moaz@example.com")
+	if _, allowed := throttle.Allow("moaz@example.com"); !allowed {
+		t.Fatal("expected Reset to clear the failures")
+	}
+}
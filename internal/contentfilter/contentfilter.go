@@ -0,0 +1,279 @@
+// Package contentfilter matches chirps against a set of word / phrase rules.
+// Matching is case-insensitive and Unicode-aware, it works on words (runs of letters & digits)
+// so punctuation around a word ("Kerfuffle!") or inside it ("k.e.r.f.u.f.f.l.e") doesn't hide it.
+package contentfilter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces the matched text with ****
+	ActionMask Action = "mask"
+	// ActionReject refuses the whole chirp
+	ActionReject Action = "reject"
+	// ActionFlag keeps the chirp as is, but sends it to the moderators for review
+	ActionFlag Action = "flag"
+)
+
+const maskReplacement = "****"
+
+func ParseAction(action string) (Action, error) {
+	switch Action(action) {
+	case ActionMask, ActionReject, ActionFlag:
+		return Action(action), nil
+	}
+
+	return "", fmt.Errorf("unknown content filter action %q", action)
+}
+
+type Rule struct {
+	Pattern string
+	Action  Action
+}
+
+// RuleSource is where the filter loads its rules from (the database, a file, ...)
+type RuleSource interface {
+	LoadRules(ctx context.Context) ([]Rule, error)
+}
+
+// Match is a rule that matched, along with the text it matched
+type Match struct {
+	Rule Rule
+	Text string
+}
+
+type Result struct {
+	// Body is the input with every mask match replaced
+	Body     string
+	Rejected bool
+	Flagged  bool
+	Matches  []Match
+}
+
+type compiledRule struct {
+	rule  Rule
+	words []string
+}
+
+// Filter holds the current rules, they can be swapped at runtime while the filter is in use
+type Filter struct {
+	mu     sync.RWMutex
+	source RuleSource
+	rules  []compiledRule
+}
+
+func New(source RuleSource) *Filter {
+	return &Filter{source: source}
+}
+
+// Reload replaces the rules with the ones currently in the source
+func (f *Filter) Reload(ctx context.Context) error {
+	rules, err := f.source.LoadRules(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't load the content filter rules: %w", err)
+	}
+
+	f.SetRules(rules)
+	return nil
+}
+
+// SetRules replaces the rules, rules without any word in their pattern are ignored
+func (f *Filter) SetRules(rules []Rule) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		words := []string{}
+		for _, token := range tokenize(rule.Pattern) {
+			words = append(words, token.norm)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		compiled = append(compiled, compiledRule{rule: rule, words: words})
+	}
+
+	f.mu.Lock()
+	f.rules = compiled
+	f.mu.Unlock()
+}
+
+// Apply runs the body through every rule
+func (f *Filter) Apply(body string) Result {
+	f.mu.RLock()
+	rules := f.rules
+	f.mu.RUnlock()
+
+	result := Result{Body: body}
+	tokens := tokenize(body)
+
+	// byte ranges of the body to replace with the mask
+	type span struct{ start, end int }
+	masked := []span{}
+
+	for i := 0; i < len(tokens); i++ {
+		for _, rule := range rules {
+			end, ok := matchAt(tokens, i, rule.words)
+			if !ok {
+				continue
+			}
+
+			start, stop := tokens[i].start, tokens[end].end
+			result.Matches = append(result.Matches, Match{Rule: rule.rule, Text: body[start:stop]})
+
+			switch rule.rule.Action {
+			case ActionReject:
+				result.Rejected = true
+			case ActionFlag:
+				result.Flagged = true
+			case ActionMask:
+				masked = append(masked, span{start, stop})
+			}
+		}
+	}
+
+	if len(masked) == 0 {
+		return result
+	}
+
+	// spans are ordered by start, overlapping ones are merged into a single mask
+	var builder strings.Builder
+	cursor := 0
+	for _, s := range masked {
+		if s.start < cursor {
+			if s.end > cursor {
+				cursor = s.end
+			}
+			continue
+		}
+		builder.WriteString(body[cursor:s.start])
+		builder.WriteString(maskReplacement)
+		cursor = s.end
+	}
+	builder.WriteString(body[cursor:])
+	result.Body = builder.String()
+
+	return result
+}
+
+// Matches reports whether any rule matches the body, whatever its action
+func (f *Filter) Matches(body string) bool {
+	f.mu.RLock()
+	rules := f.rules
+	f.mu.RUnlock()
+
+	tokens := tokenize(body)
+	for i := range tokens {
+		for _, rule := range rules {
+			if _, ok := matchAt(tokens, i, rule.words); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Rules returns a copy of the current rules
+func (f *Filter) Rules() []Rule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	rules := make([]Rule, len(f.rules))
+	for i, compiled := range f.rules {
+		rules[i] = compiled.rule
+	}
+	return rules
+}
+
+type token struct {
+	// normalized (lower-cased) word
+	norm string
+	// byte range in the original text
+	start, end int
+	// whether whitespace separates this token from the previous one
+	spaceBefore bool
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// tokenize splits the text into words, everything else (spaces, punctuation, emoji) separates them
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	sawSpace := false
+
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		tokens = append(tokens, token{
+			norm:        strings.ToLower(text[start:end]),
+			start:       start,
+			end:         end,
+			spaceBefore: sawSpace || len(tokens) == 0,
+		})
+		start = -1
+		sawSpace = false
+	}
+
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		flush(i)
+		if unicode.IsSpace(r) {
+			sawSpace = true
+		}
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// matchAt tries to match the rule words starting at tokens[i], returning the index of the last matched token.
+// A rule word may be spread over several tokens as long as only punctuation separates them ("ker-fuf.fle"),
+// while the words of a phrase may be separated by anything.
+func matchAt(tokens []token, i int, words []string) (int, bool) {
+	current := i
+	for _, word := range words {
+		remaining := word
+		first := true
+		for remaining != "" {
+			if current >= len(tokens) {
+				return 0, false
+			}
+			// within a word, only punctuation may separate the pieces
+			if !first && tokens[current].spaceBefore {
+				return 0, false
+			}
+
+			piece := tokens[current].norm
+			if !strings.HasPrefix(remaining, piece) {
+				return 0, false
+			}
+			remaining = remaining[len(piece):]
+			current++
+			first = false
+		}
+	}
+
+	// pieces are whole tokens, so "kerfuffles" never matches "kerfuffle"
+	return current - 1, true
+}
+
+// ValidPattern reports whether the pattern has at least one word to match, rules without any are ignored
+func ValidPattern(pattern string) bool {
+	return len(tokenize(pattern)) > 0
+}
@@ -0,0 +1,153 @@
+package contentfilter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func defaultFilter() *Filter {
+	filter := New(nil)
+	filter.SetRules([]Rule{
+		{Pattern: "kerfuffle", Action: ActionMask},
+		{Pattern: "sharbert", Action: ActionMask},
+		{Pattern: "fornax", Action: ActionMask},
+	})
+	return filter
+}
+
+func TestApplyMasks(t *testing.T) {
+	filter := defaultFilter()
+
+	tests := map[string]string{
+		"This is a kerfuffle opinion I need to share with the world": "This is a **** opinion I need to share with the world",
+		"What a Kerfuffle!":           "What a ****!",
+		"KERFUFFLE, SHARBERT, FORNAX": "****, ****, ****",
+		"a k.e.r.f.u.f.f.l.e here":    "a **** here",
+		"ker-fuffle":                  "****",
+		"kerfuffles are fine":         "kerfuffles are fine",
+		"ker fuffle is two words":     "ker fuffle is two words",
+		"#sharbert":                   "#****",
+		"Ｋｅｒｆｕｆｆｌｅ is not ascii":      "Ｋｅｒｆｕｆｆｌｅ is not ascii",
+		"nothing to see here":         "nothing to see here",
+		"مرحبا fornax 🚀":              "مرحبا **** 🚀",
+	}
+
+	for body, expected := range tests {
+		result := filter.Apply(body)
+		if result.Body != expected {
+			t.Errorf("%q: expected %q, got %q", body, expected, result.Body)
+		}
+		if result.Rejected || result.Flagged {
+			t.Errorf("%q: mask rules shouldn't reject or flag", body)
+		}
+	}
+}
+
+func TestApplyUnicodeCase(t *testing.T) {
+	filter := New(nil)
+	filter.SetRules([]Rule{{Pattern: "ÉCLAIR", Action: ActionMask}})
+
+	result := filter.Apply("un éclair au chocolat")
+	if result.Body != "un **** au chocolat" {
+		t.Errorf("expected a case-insensitive unicode match, got %q", result.Body)
+	}
+}
+
+func TestApplyPhrases(t *testing.T) {
+	filter := New(nil)
+	filter.SetRules([]Rule{
+		{Pattern: "buy now", Action: ActionReject},
+		{Pattern: "crypto", Action: ActionFlag},
+	})
+
+	result := filter.Apply("BUY... NOW!!")
+	if !result.Rejected {
+		t.Error("expected the phrase to reject the chirp")
+	}
+	if len(result.Matches) != 1 || result.Matches[0].Text != "BUY... NOW" {
+		t.Errorf("expected a single match on the phrase, got %v", result.Matches)
+	}
+
+	result = filter.Apply("buy things now")
+	if result.Rejected {
+		t.Error("expected the phrase words to be next to each other")
+	}
+
+	result = filter.Apply("all about Crypto")
+	if !result.Flagged || result.Rejected {
+		t.Errorf("expected the chirp to be flagged only, got %+v", result)
+	}
+	if result.Body != "all about Crypto" {
+		t.Errorf("flag rules shouldn't change the body, got %q", result.Body)
+	}
+}
+
+func TestApplyOverlappingMasks(t *testing.T) {
+	filter := New(nil)
+	filter.SetRules([]Rule{
+		{Pattern: "bad", Action: ActionMask},
+		{Pattern: "bad word", Action: ActionMask},
+		{Pattern: "", Action: ActionMask},
+	})
+
+	result := filter.Apply("a bad word here")
+	if result.Body != "a **** here" {
+		t.Errorf("expected overlapping masks to merge, got %q", result.Body)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	filter := New(nil)
+	filter.SetRules([]Rule{
+		{Pattern: "spoiler alert", Action: ActionMask},
+		{Pattern: "fornax", Action: ActionFlag},
+	})
+
+	tests := map[string]bool{
+		"SPOILER... alert: he survives": true,
+		"no spoilers here, alert":       false,
+		"f.o.r.n.a.x":                   true,
+		"fornaxes":                      false,
+		"":                              false,
+	}
+
+	for body, expected := range tests {
+		if got := filter.Matches(body); got != expected {
+			t.Errorf("%q: expected %t, got %t", body, expected, got)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	content := "# default words\nkerfuffle\nreject buy now\nflag crypto\n\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	filter := New(MultiSource{FileSource{Path: path}})
+	if err := filter.Reload(context.Background()); err != nil {
+		t.Fatalf("couldn't load the rules: %v", err)
+	}
+
+	expected := []Rule{
+		{Pattern: "kerfuffle", Action: ActionMask},
+		{Pattern: "buy now", Action: ActionReject},
+		{Pattern: "crypto", Action: ActionFlag},
+	}
+	rules := filter.Rules()
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, got %v", len(expected), rules)
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Errorf("rule %d: expected %v, got %v", i, expected[i], rules[i])
+		}
+	}
+
+	if err := New(FileSource{Path: filepath.Join(t.TempDir(), "missing.txt")}).Reload(context.Background()); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
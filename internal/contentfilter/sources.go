@@ -0,0 +1,62 @@
+package contentfilter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileSource reads rules from a text file, one "<action> <pattern>" per line (e.g. "reject some phrase").
+// Lines holding only a pattern use the mask action, blank lines and # comments are skipped.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) LoadRules(ctx context.Context) ([]Rule, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the content filter file: %w", err)
+	}
+	defer file.Close()
+
+	rules := []Rule{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := Rule{Pattern: line, Action: ActionMask}
+		if first, rest, found := strings.Cut(line, " "); found {
+			if action, err := ParseAction(first); err == nil {
+				rule = Rule{Pattern: strings.TrimSpace(rest), Action: action}
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read the content filter file: %w", err)
+	}
+
+	return rules, nil
+}
+
+// MultiSource merges the rules of several sources, failing if any of them fails
+type MultiSource []RuleSource
+
+func (s MultiSource) LoadRules(ctx context.Context) ([]Rule, error) {
+	rules := []Rule{}
+	for _, source := range s {
+		sourceRules, err := source.LoadRules(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, sourceRules...)
+	}
+
+	return rules, nil
+}
@@ -7,23 +7,55 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const cancelScheduledChirp = `-- name: CancelScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1
+  AND user_id = $2
+  AND published_at IS NULL
+`
+
+type CancelScheduledChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) CancelScheduledChirp(ctx context.Context, arg CancelScheduledChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelScheduledChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createChirpy = `-- name: CreateChirpy :one
-INSERT INTO chirps(id, body, user_id, created_at, updated_at)
-VALUES($1, $2, $3, $4, $5)
-RETURNING id, body, user_id, created_at, updated_at
+INSERT INTO chirps(
+    id,
+    body,
+    user_id,
+    created_at,
+    updated_at,
+    publish_at,
+    published_at
+  )
+VALUES($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, body, user_id, created_at, updated_at, hidden_at, publish_at, published_at, deleted_at, deleted_by
 `
 
 type CreateChirpyParams struct {
-	ID        uuid.UUID
-	Body      string
-	UserID    uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	PublishAt   sql.NullTime
+	PublishedAt sql.NullTime
 }
 
 func (q *Queries) CreateChirpy(ctx context.Context, arg CreateChirpyParams) (Chirp, error) {
@@ -33,6 +65,8 @@ func (q *Queries) CreateChirpy(ctx context.Context, arg CreateChirpyParams) (Chi
 		arg.UserID,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.PublishAt,
+		arg.PublishedAt,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -41,34 +75,69 @@ func (q *Queries) CreateChirpy(ctx context.Context, arg CreateChirpyParams) (Chi
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
+const deleteChirpsByIDs = `-- name: DeleteChirpsByIDs :exec
 DELETE FROM chirps
-WHERE id = $1
-  AND user_id = $2
+WHERE id = ANY($1::uuid[])
 `
 
-type DeleteChirpParams struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
-}
-
-func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
+func (q *Queries) DeleteChirpsByIDs(ctx context.Context, ids []uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpsByIDs, pq.Array(ids))
 	return err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, body, user_id, created_at, updated_at
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.hidden_at, chirps.publish_at, chirps.published_at, chirps.deleted_at, chirps.deleted_by
 FROM chirps
-ORDER BY created_at ASC
+  JOIN users ON users.id = chirps.user_id
+WHERE users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE (
+        user_blocks.blocker_id = chirps.user_id
+        AND user_blocks.blocked_id = $1::uuid
+      )
+      OR (
+        user_blocks.blocker_id = $1::uuid
+        AND user_blocks.blocked_id = chirps.user_id
+      )
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_mutes
+    WHERE user_mutes.muter_id = $1::uuid
+      AND user_mutes.muted_id = chirps.user_id
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = $1::uuid
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = $1::uuid
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  )
+ORDER BY chirps.published_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+// the viewer (null when anonymous) doesn't see chirps across a block in either direction, nor the ones of users they muted,
+// and only sees protected accounts they follow
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +151,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UserID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -97,14 +171,42 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, body, user_id, created_at, updated_at
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.hidden_at, chirps.publish_at, chirps.published_at, chirps.deleted_at, chirps.deleted_by
 FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+  JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1::uuid
+  AND users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = $2::uuid
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = $2::uuid
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = $2::uuid
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  )
+ORDER BY chirps.published_at ASC
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+type GetChirpsByUserIDParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+// a profile is empty for the users its author blocked, and for non-followers when it's protected, muting doesn't hide it
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +220,11 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UserID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -133,9 +240,10 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 }
 
 const getChirpy = `-- name: GetChirpy :one
-SELECT id, body, user_id, created_at, updated_at
+SELECT id, body, user_id, created_at, updated_at, hidden_at, publish_at, published_at, deleted_at, deleted_by
 From chirps
 WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirpy(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -147,15 +255,21 @@ func (q *Queries) GetChirpy(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
 	)
 	return i, err
 }
 
 const getChirpyByUserID = `-- name: GetChirpyByUserID :one
-SELECT id, body, user_id, created_at, updated_at
+SELECT id, body, user_id, created_at, updated_at, hidden_at, publish_at, published_at, deleted_at, deleted_by
 FROM chirps
 WHERE id = $1
   AND user_id = $2
+  AND deleted_at IS NULL
 `
 
 type GetChirpyByUserIDParams struct {
@@ -172,6 +286,393 @@ func (q *Queries) GetChirpyByUserID(ctx context.Context, arg GetChirpyByUserIDPa
 		&i.UserID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
 	)
 	return i, err
 }
+
+const getPurgeableChirpIDs = `-- name: GetPurgeableChirpIDs :many
+SELECT id
+FROM chirps
+WHERE deleted_at < $1::timestamp
+ORDER BY deleted_at ASC
+LIMIT $2::int FOR UPDATE SKIP LOCKED
+`
+
+type GetPurgeableChirpIDsParams struct {
+	DeletedBefore time.Time
+	BatchSize     int32
+}
+
+func (q *Queries) GetPurgeableChirpIDs(ctx context.Context, arg GetPurgeableChirpIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getPurgeableChirpIDs, arg.DeletedBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.hidden_at, chirps.publish_at, chirps.published_at, chirps.deleted_at, chirps.deleted_by
+FROM chirps
+  JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1::uuid
+  AND users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = $2::uuid
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = $2::uuid
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = $2::uuid
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  )
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+// a published chirp as the viewer (null when anonymous) may see it, following blocks & protected accounts
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.Body,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.HiddenAt,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.DeletedAt,
+		&i.DeletedBy,
+	)
+	return i, err
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = $1,
+  updated_at = $2
+WHERE id = $3
+`
+
+type HideChirpParams struct {
+	HiddenAt  sql.NullTime
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) HideChirp(ctx context.Context, arg HideChirpParams) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, arg.HiddenAt, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const listDeletedChirps = `-- name: ListDeletedChirps :many
+SELECT id, body, user_id, created_at, updated_at, hidden_at, publish_at, published_at, deleted_at, deleted_by
+FROM chirps
+WHERE user_id = $1::uuid
+  AND deleted_by = $1::uuid
+  AND deleted_at >= $2::timestamp
+ORDER BY deleted_at DESC
+`
+
+type ListDeletedChirpsParams struct {
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) ListDeletedChirps(ctx context.Context, arg ListDeletedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listDeletedChirps, arg.UserID, arg.DeletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, body, user_id, created_at, updated_at, hidden_at, publish_at, published_at, deleted_at, deleted_by
+FROM chirps
+WHERE user_id = $1
+  AND published_at IS NULL
+  AND deleted_at IS NULL
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTopFollowedChirpsSince = `-- name: ListTopFollowedChirpsSince :many
+SELECT chirps.id, chirps.body, chirps.user_id, chirps.created_at, chirps.updated_at, chirps.hidden_at, chirps.publish_at, chirps.published_at, chirps.deleted_at, chirps.deleted_by
+FROM chirps
+  JOIN users ON users.id = chirps.user_id
+  JOIN follows ON follows.followee_id = chirps.user_id
+  AND follows.follower_id = $1::uuid
+  AND follows.status = 'accepted'
+WHERE users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at >= $2::timestamp
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_mutes
+    WHERE user_mutes.muter_id = $1::uuid
+      AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY (
+    SELECT COUNT(*)
+    FROM bookmarks
+    WHERE bookmarks.chirp_id = chirps.id
+  ) DESC,
+  chirps.published_at DESC
+LIMIT $3
+`
+
+type ListTopFollowedChirpsSinceParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+	Limit  int32
+}
+
+// the most bookmarked chirps the user's followed accounts published since the given time, for the digest
+func (q *Queries) ListTopFollowedChirpsSince(ctx context.Context, arg ListTopFollowedChirpsSinceParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTopFollowedChirpsSince, arg.UserID, arg.Since, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps
+SET published_at = publish_at,
+  updated_at = $1::timestamp
+WHERE id IN (
+    SELECT id
+    FROM chirps
+    WHERE published_at IS NULL
+      AND deleted_at IS NULL
+      AND publish_at <= $1::timestamp
+    ORDER BY publish_at ASC
+    LIMIT $2::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING id, body, user_id, created_at, updated_at, hidden_at, publish_at, published_at, deleted_at, deleted_by
+`
+
+type PublishDueChirpsParams struct {
+	Now       time.Time
+	BatchSize int32
+}
+
+func (q *Queries) PublishDueChirps(ctx context.Context, arg PublishDueChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.Body,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.HiddenAt,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreChirp = `-- name: RestoreChirp :execrows
+UPDATE chirps
+SET deleted_at = NULL,
+  deleted_by = NULL,
+  updated_at = $1::timestamp
+WHERE id = $2::uuid
+  AND user_id = $3::uuid
+  AND deleted_by = $3::uuid
+  AND deleted_at >= $4::timestamp
+`
+
+type RestoreChirpParams struct {
+	UpdatedAt    time.Time
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreChirp,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.DeletedAfter,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = $1,
+  deleted_by = $2,
+  updated_at = $3
+WHERE id = $4
+  AND user_id = $5
+  AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpParams struct {
+	DeletedAt sql.NullTime
+	DeletedBy uuid.NullUUID
+	UpdatedAt time.Time
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteChirp,
+		arg.DeletedAt,
+		arg.DeletedBy,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
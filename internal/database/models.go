@@ -6,19 +6,138 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type BackgroundJob struct {
+	ID        uuid.UUID
+	Kind      string
+	Payload   json.RawMessage
+	Attempts  int32
+	RunAt     time.Time
+	LastError string
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpReport struct {
+	ID            uuid.UUID
+	ChirpID       uuid.NullUUID
+	ChirpAuthorID uuid.NullUUID
+	ChirpBody     string
+	ReporterID    uuid.NullUUID
+	Reason        string
+	Details       string
+	Status        string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ResolvedAt    sql.NullTime
+}
+
 type Chirp struct {
+	ID          uuid.UUID
+	Body        string
+	UserID      uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	HiddenAt    sql.NullTime
+	PublishAt   sql.NullTime
+	PublishedAt sql.NullTime
+	DeletedAt   sql.NullTime
+	DeletedBy   uuid.NullUUID
+}
+
+type ContentFilterRule struct {
+	ID        uuid.UUID
+	Pattern   string
+	Action    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	UserA     uuid.UUID
+	UserB     uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Draft struct {
 	ID        uuid.UUID
-	Body      string
 	UserID    uuid.UUID
+	Body      string
+	MediaIds  []uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type MediaAttachment struct {
+	ID                   uuid.UUID
+	UserID               uuid.UUID
+	ChirpID              uuid.NullUUID
+	Position             int32
+	ContentType          string
+	Width                int32
+	Height               int32
+	SizeBytes            int64
+	StorageKey           string
+	ThumbnailContentType string
+	ThumbnailKey         string
+	CreatedAt            time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+	ReadAt         sql.NullTime
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID
+	ReportID    uuid.UUID
+	ModeratorID uuid.NullUUID
+	Action      string
+	Note        string
+	CreatedAt   time.Time
+}
+
+type MutedWord struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Phrase    string
+	ExpiresAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Type      string
+	ActorID   uuid.NullUUID
+	CreatedAt time.Time
+	ReadAt    sql.NullTime
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -28,11 +147,75 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 }
 
+type StreamEvent struct {
+	ID        int64
+	UserID    uuid.UUID
+	Type      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type User struct {
+	ID                       uuid.UUID
+	Email                    string
+	HashedPassword           string
+	IsChirpyRed              bool
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
+	Role                     string
+	SuspendedAt              sql.NullTime
+	SuspensionReason         string
+	ChirpsHidden             bool
+	PasswordResetRequired    bool
+	AvatarID                 uuid.NullUUID
+	AvatarContentType        string
+	PinnedChirpID            uuid.NullUUID
+	IsProtected              bool
+	DigestFrequency          string
+	DigestSentAt             sql.NullTime
+	DigestUnsubscribeToken   sql.NullString
+	AvatarUpdatedAt          sql.NullTime
+	DigestConfirmedEmail     sql.NullString
+	DigestPendingFrequency   sql.NullString
+	DigestConfirmationToken  sql.NullString
+	DigestConfirmationSentAt sql.NullTime
+	DigestClaimedUntil       sql.NullTime
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	SubscriptionID uuid.UUID
+	Event          string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastResponse   string
+	LastError      string
+	DeliveredAt    sql.NullTime
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
+
+type WebhookSubscription struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -96,6 +96,25 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, userID uuid.UUID)
 	return i, err
 }
 
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = $1,
+  updated_at = $2
+WHERE user_id = $3
+  AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokensParams struct {
+	RevokedAt sql.NullTime
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+}
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, arg RevokeUserRefreshTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, arg.RevokedAt, arg.UpdatedAt, arg.UserID)
+	return err
+}
+
 const updateRefreshToken = `-- name: UpdateRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = $1,
@@ -7,21 +7,201 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const claimDueDigests = `-- name: ClaimDueDigests :many
+UPDATE users
+SET digest_claimed_until = $1::timestamp
+WHERE id IN (
+    SELECT id
+    FROM users
+    WHERE suspended_at IS NULL
+      AND (
+        digest_claimed_until IS NULL
+        OR digest_claimed_until <= $2::timestamp
+      )
+      -- the address changed since it was confirmed
+      AND digest_confirmed_email = email
+      AND (
+        (
+          digest_frequency = 'daily'
+          AND (
+            digest_sent_at IS NULL
+            OR digest_sent_at <= $3::timestamp
+          )
+        )
+        OR (
+          digest_frequency = 'weekly'
+          AND (
+            digest_sent_at IS NULL
+            OR digest_sent_at <= $4::timestamp
+          )
+        )
+      )
+    ORDER BY digest_sent_at ASC NULLS FIRST
+    LIMIT $5::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type ClaimDueDigestsParams struct {
+	LeaseUntil   time.Time
+	Now          time.Time
+	DailyBefore  time.Time
+	WeeklyBefore time.Time
+	BatchSize    int32
+}
+
+// leases a batch of due digests, FOR UPDATE SKIP LOCKED lets replicas split the work.
+// A digest that wasn't marked sent when its lease is over (failed delivery, crash) is claimed again
+func (q *Queries) ClaimDueDigests(ctx context.Context, arg ClaimDueDigestsParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueDigests,
+		arg.LeaseUntil,
+		arg.Now,
+		arg.DailyBefore,
+		arg.WeeklyBefore,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspensionReason,
+			&i.ChirpsHidden,
+			&i.PasswordResetRequired,
+			&i.AvatarID,
+			&i.AvatarContentType,
+			&i.PinnedChirpID,
+			&i.IsProtected,
+			&i.DigestFrequency,
+			&i.DigestSentAt,
+			&i.DigestUnsubscribeToken,
+			&i.AvatarUpdatedAt,
+			&i.DigestConfirmedEmail,
+			&i.DigestPendingFrequency,
+			&i.DigestConfirmationToken,
+			&i.DigestConfirmationSentAt,
+			&i.DigestClaimedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const confirmDigest = `-- name: ConfirmDigest :one
+UPDATE users
+SET digest_frequency = digest_pending_frequency,
+  digest_confirmed_email = email,
+  digest_pending_frequency = NULL,
+  digest_confirmation_token = NULL,
+  updated_at = $1
+WHERE digest_confirmation_token = $2::text
+  AND digest_pending_frequency IS NOT NULL
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type ConfirmDigestParams struct {
+	UpdatedAt time.Time
+	Token     string
+}
+
+func (q *Queries) ConfirmDigest(ctx context.Context, arg ConfirmDigestParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmDigest, arg.UpdatedAt, arg.Token)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+WHERE (
+    $1::text IS NULL
+    OR email ILIKE '%' || $1::text || '%' ESCAPE '\'
+  )
+  AND (
+    $2::text IS NULL
+    OR role = $2::text
+  )
+  AND (
+    $3::bool IS NULL
+    OR (suspended_at IS NOT NULL) = $3::bool
+  )
+`
+
+type CountUsersParams struct {
+	Search    sql.NullString
+	Role      sql.NullString
+	Suspended sql.NullBool
+}
+
+func (q *Queries) CountUsers(ctx context.Context, arg CountUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers, arg.Search, arg.Role, arg.Suspended)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     id,
     created_at,
     updated_at,
     email,
-    hashed_password
+    hashed_password,
+    role
   )
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
 `
 
 type CreateUserParams struct {
@@ -30,6 +210,7 @@ type CreateUserParams struct {
 	UpdatedAt      time.Time
 	Email          string
 	HashedPassword string
+	Role           string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -39,6 +220,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.UpdatedAt,
 		arg.Email,
 		arg.HashedPassword,
+		arg.Role,
 	)
 	var i User
 	err := row.Scan(
@@ -48,6 +230,24 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.IsChirpyRed,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
 	)
 	return i, err
 }
@@ -61,8 +261,122 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const getUserByAvatarID = `-- name: GetUserByAvatarID :one
+SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+FROM users
+WHERE avatar_id = $1
+`
+
+func (q *Queries) GetUserByAvatarID(ctx context.Context, avatarID uuid.NullUUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByAvatarID, avatarID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const getUserByDigestConfirmationToken = `-- name: GetUserByDigestConfirmationToken :one
+SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+FROM users
+WHERE digest_confirmation_token = $1::text
+`
+
+func (q *Queries) GetUserByDigestConfirmationToken(ctx context.Context, token string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByDigestConfirmationToken, token)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const getUserByDigestUnsubscribeToken = `-- name: GetUserByDigestUnsubscribeToken :one
+SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+FROM users
+WHERE digest_unsubscribe_token = $1::text
+`
+
+func (q *Queries) GetUserByDigestUnsubscribeToken(ctx context.Context, token string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByDigestUnsubscribeToken, token)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at
+SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
 FROM users
 WHERE email = $1
 `
@@ -77,12 +391,30 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.IsChirpyRed,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at
+SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
 FROM users
 WHERE id = $1
 `
@@ -97,6 +429,604 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.IsChirpyRed,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+FROM users
+WHERE (
+    $1::text IS NULL
+    OR email ILIKE '%' || $1::text || '%' ESCAPE '\'
+  )
+  AND (
+    $2::text IS NULL
+    OR role = $2::text
+  )
+  AND (
+    $3::bool IS NULL
+    OR (suspended_at IS NOT NULL) = $3::bool
+  )
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $5
+`
+
+type ListUsersParams struct {
+	Search    sql.NullString
+	Role      sql.NullString
+	Suspended sql.NullBool
+	Limit     int32
+	Offset    int32
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.Search,
+		arg.Role,
+		arg.Suspended,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SuspensionReason,
+			&i.ChirpsHidden,
+			&i.PasswordResetRequired,
+			&i.AvatarID,
+			&i.AvatarContentType,
+			&i.PinnedChirpID,
+			&i.IsProtected,
+			&i.DigestFrequency,
+			&i.DigestSentAt,
+			&i.DigestUnsubscribeToken,
+			&i.AvatarUpdatedAt,
+			&i.DigestConfirmedEmail,
+			&i.DigestPendingFrequency,
+			&i.DigestConfirmationToken,
+			&i.DigestConfirmationSentAt,
+			&i.DigestClaimedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markDigestSent = `-- name: MarkDigestSent :exec
+UPDATE users
+SET digest_sent_at = $1::timestamp,
+  digest_claimed_until = NULL
+WHERE id = $2
+`
+
+type MarkDigestSentParams struct {
+	SentAt time.Time
+	ID     uuid.UUID
+}
+
+func (q *Queries) MarkDigestSent(ctx context.Context, arg MarkDigestSentParams) error {
+	_, err := q.db.ExecContext(ctx, markDigestSent, arg.SentAt, arg.ID)
+	return err
+}
+
+const pinChirp = `-- name: PinChirp :exec
+UPDATE users
+SET pinned_chirp_id = $1,
+  updated_at = $2
+WHERE id = $3
+`
+
+type PinChirpParams struct {
+	PinnedChirpID uuid.NullUUID
+	UpdatedAt     time.Time
+	ID            uuid.UUID
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, pinChirp, arg.PinnedChirpID, arg.UpdatedAt, arg.ID)
+	return err
+}
+
+const requestDigestConfirmation = `-- name: RequestDigestConfirmation :one
+UPDATE users
+SET digest_pending_frequency = $1::text,
+  digest_confirmation_token = $2::text,
+  digest_confirmation_sent_at = $3::timestamp,
+  digest_unsubscribe_token = COALESCE(
+    digest_unsubscribe_token,
+    $4::text
+  ),
+  updated_at = $3
+WHERE id = $5
+  AND (
+    digest_confirmation_sent_at IS NULL
+    OR digest_confirmation_sent_at <= $6::timestamp
+  )
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type RequestDigestConfirmationParams struct {
+	PendingFrequency  string
+	ConfirmationToken string
+	UpdatedAt         time.Time
+	UnsubscribeToken  string
+	ID                uuid.UUID
+	ResendBefore      time.Time
+}
+
+// keeps the frequency asked for until the address is confirmed with the token, no row during the resend cooldown
+func (q *Queries) RequestDigestConfirmation(ctx context.Context, arg RequestDigestConfirmationParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, requestDigestConfirmation,
+		arg.PendingFrequency,
+		arg.ConfirmationToken,
+		arg.UpdatedAt,
+		arg.UnsubscribeToken,
+		arg.ID,
+		arg.ResendBefore,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const requirePasswordReset = `-- name: RequirePasswordReset :one
+UPDATE users
+SET password_reset_required = TRUE,
+  updated_at = $1
+WHERE id = $2
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type RequirePasswordResetParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) RequirePasswordReset(ctx context.Context, arg RequirePasswordResetParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, requirePasswordReset, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const setDigestFrequency = `-- name: SetDigestFrequency :one
+UPDATE users
+SET digest_frequency = $1,
+  digest_unsubscribe_token = COALESCE(
+    digest_unsubscribe_token,
+    $2::text
+  ),
+  digest_pending_frequency = NULL,
+  digest_confirmation_token = NULL,
+  updated_at = $3
+WHERE id = $4
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type SetDigestFrequencyParams struct {
+	DigestFrequency  string
+	UnsubscribeToken string
+	UpdatedAt        time.Time
+	ID               uuid.UUID
+}
+
+// the unsubscribe token is created the first time, and kept afterwards so the links already sent keep working
+func (q *Queries) SetDigestFrequency(ctx context.Context, arg SetDigestFrequencyParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setDigestFrequency,
+		arg.DigestFrequency,
+		arg.UnsubscribeToken,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const setUserAvatar = `-- name: SetUserAvatar :one
+UPDATE users
+SET avatar_id = $1,
+  avatar_content_type = $2,
+  avatar_updated_at = $3,
+  updated_at = $4
+WHERE id = $5
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type SetUserAvatarParams struct {
+	AvatarID          uuid.NullUUID
+	AvatarContentType string
+	AvatarUpdatedAt   sql.NullTime
+	UpdatedAt         time.Time
+	ID                uuid.UUID
+}
+
+func (q *Queries) SetUserAvatar(ctx context.Context, arg SetUserAvatarParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserAvatar,
+		arg.AvatarID,
+		arg.AvatarContentType,
+		arg.AvatarUpdatedAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const setUserProtected = `-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $1,
+  updated_at = $2
+WHERE id = $3
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type SetUserProtectedParams struct {
+	IsProtected bool
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserProtected(ctx context.Context, arg SetUserProtectedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserProtected, arg.IsProtected, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $1,
+  updated_at = $2
+WHERE id = $3
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type SetUserRoleParams struct {
+	Role      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = $1,
+  suspension_reason = $2,
+  chirps_hidden = $3,
+  updated_at = $4
+WHERE id = $5
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type SuspendUserParams struct {
+	SuspendedAt      sql.NullTime
+	SuspensionReason string
+	ChirpsHidden     bool
+	UpdatedAt        time.Time
+	ID               uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser,
+		arg.SuspendedAt,
+		arg.SuspensionReason,
+		arg.ChirpsHidden,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
+	)
+	return i, err
+}
+
+const unpinChirp = `-- name: UnpinChirp :execrows
+UPDATE users
+SET pinned_chirp_id = NULL,
+  updated_at = $1
+WHERE id = $2
+  AND pinned_chirp_id = $3
+`
+
+type UnpinChirpParams struct {
+	UpdatedAt     time.Time
+	ID            uuid.UUID
+	PinnedChirpID uuid.NullUUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinChirp, arg.UpdatedAt, arg.ID, arg.PinnedChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unsubscribeFromDigest = `-- name: UnsubscribeFromDigest :execrows
+UPDATE users
+SET digest_frequency = 'off',
+  updated_at = $1
+WHERE digest_unsubscribe_token = $2::text
+`
+
+type UnsubscribeFromDigestParams struct {
+	UpdatedAt time.Time
+	Token     string
+}
+
+func (q *Queries) UnsubscribeFromDigest(ctx context.Context, arg UnsubscribeFromDigestParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsubscribeFromDigest, arg.UpdatedAt, arg.Token)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+  suspension_reason = '',
+  chirps_hidden = FALSE,
+  updated_at = $1
+WHERE id = $2
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
+`
+
+type UnsuspendUserParams struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UnsuspendUser(ctx context.Context, arg UnsuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
 	)
 	return i, err
 }
@@ -104,9 +1034,16 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $1,
-  hashed_password = $2
+  hashed_password = $2,
+  password_reset_required = FALSE,
+  digest_pending_frequency = CASE
+    WHEN email = $1 THEN digest_pending_frequency
+  END,
+  digest_confirmation_token = CASE
+    WHEN email = $1 THEN digest_confirmation_token
+  END
 WHERE id = $3
-RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at
+RETURNING id, email, hashed_password, is_chirpy_red, created_at, updated_at, role, suspended_at, suspension_reason, chirps_hidden, password_reset_required, avatar_id, avatar_content_type, pinned_chirp_id, is_protected, digest_frequency, digest_sent_at, digest_unsubscribe_token, avatar_updated_at, digest_confirmed_email, digest_pending_frequency, digest_confirmation_token, digest_confirmation_sent_at, digest_claimed_until
 `
 
 type UpdateUserParams struct {
@@ -115,6 +1052,7 @@ type UpdateUserParams struct {
 	ID             uuid.UUID
 }
 
+// a pending digest confirmation was sent to the previous address, it can't confirm the new one
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
 	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
 	var i User
@@ -125,17 +1063,56 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.IsChirpyRed,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SuspensionReason,
+		&i.ChirpsHidden,
+		&i.PasswordResetRequired,
+		&i.AvatarID,
+		&i.AvatarContentType,
+		&i.PinnedChirpID,
+		&i.IsProtected,
+		&i.DigestFrequency,
+		&i.DigestSentAt,
+		&i.DigestUnsubscribeToken,
+		&i.AvatarUpdatedAt,
+		&i.DigestConfirmedEmail,
+		&i.DigestPendingFrequency,
+		&i.DigestConfirmationToken,
+		&i.DigestConfirmationSentAt,
+		&i.DigestClaimedUntil,
 	)
 	return i, err
 }
 
-const upgradeUserToRed = `-- name: UpgradeUserToRed :exec
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
+
+const upgradeUserToRed = `-- name: UpgradeUserToRed :execrows
 UPDATE users
 SET is_chirpy_red = TRUE
 WHERE id = $1
+  AND is_chirpy_red = FALSE
 `
 
-func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserToRed, id)
-	return err
+// no row when the user already has it, Polka retries its webhooks
+func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upgradeUserToRed, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
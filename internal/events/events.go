@@ -0,0 +1,118 @@
+// Package events fans real-time events out to the connected clients of each user.
+// The Broker works within a single process, Listen bridges it to Postgres LISTEN/NOTIFY
+// so an event stored by any replica reaches the clients connected to the others.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a stored event, ID grows with every event so clients can resume after the last one they saw.
+// Ephemeral events (typing indicators, ...) aren't stored and have no ID.
+type Event struct {
+	ID        int64           `json:"id,omitempty"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Ephemeral reports whether the event was sent without being stored, a client can't resume after it
+func (e Event) Ephemeral() bool {
+	return e.ID == 0
+}
+
+// Subscription receives the events of one user, C is closed when the subscription ends
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	userID uuid.UUID
+	broker *Broker
+	once   sync.Once
+}
+
+// Close ends the subscription, it's safe to call more than once
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Broker delivers the published events to the subscriptions of their user
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[uuid.UUID]map[*Subscription]struct{}
+	buffer int
+}
+
+// NewBroker returns a broker whose subscriptions buffer up to buffer events.
+// A subscriber falling further behind is dropped, its client resumes from the stored events.
+func NewBroker(buffer int) *Broker {
+	return &Broker{
+		subs:   map[uuid.UUID]map[*Subscription]struct{}{},
+		buffer: buffer,
+	}
+}
+
+func (b *Broker) Subscribe(userID uuid.UUID) *Subscription {
+	c := make(chan Event, b.buffer)
+	sub := &Subscription{C: c, c: c, userID: userID, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[*Subscription]struct{}{}
+	}
+	b.subs[userID][sub] = struct{}{}
+
+	return sub
+}
+
+// Publish delivers the event to the user's subscriptions without ever blocking
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[event.UserID] {
+		select {
+		case sub.c <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// HasSubscribers reports whether the user has a client connected to this process
+func (b *Broker) HasSubscribers(userID uuid.UUID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[userID]) > 0
+}
+
+// CloseAll ends every subscription, on shutdown or when events may have been missed
+func (b *Broker) CloseAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.remove(sub)
+		}
+	}
+}
+
+// remove closes the subscription, b.mu must be held
+func (b *Broker) remove(sub *Subscription) {
+	sub.once.Do(func() {
+		close(sub.c)
+		delete(b.subs[sub.userID], sub)
+		if len(b.subs[sub.userID]) == 0 {
+			delete(b.subs, sub.userID)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	broker := NewBroker(4)
+	alice, bob := uuid.New(), uuid.New()
+
+	first, second := broker.Subscribe(alice), broker.Subscribe(alice)
+	other := broker.Subscribe(bob)
+	defer first.Close()
+	defer second.Close()
+	defer other.Close()
+
+	broker.Publish(Event{ID: 1, UserID: alice, Type: "chirp.created"})
+
+	for _, sub := range []*Subscription{first, second} {
+		select {
+		case event := <-sub.C:
+			if event.ID != 1 {
+				t.Errorf("expected event 1, got %d", event.ID)
+			}
+		default:
+			t.Error("expected every subscription of the user to get the event")
+		}
+	}
+
+	select {
+	case event := <-other.C:
+		t.Errorf("another user shouldn't get the event, got %d", event.ID)
+	default:
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	broker := NewBroker(1)
+	userID := uuid.New()
+	sub := broker.Subscribe(userID)
+
+	broker.Publish(Event{ID: 1, UserID: userID})
+	broker.Publish(Event{ID: 2, UserID: userID})
+
+	if event, ok := <-sub.C; !ok || event.ID != 1 {
+		t.Fatalf("expected the buffered event first, got %d (open: %t)", event.ID, ok)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription to be closed once it fell behind")
+	}
+	if broker.HasSubscribers(userID) {
+		t.Error("expected the dropped subscription to be removed")
+	}
+
+	// closing again is a no-op
+	sub.Close()
+}
+
+func TestBrokerCloseAll(t *testing.T) {
+	broker := NewBroker(1)
+	userID := uuid.New()
+	sub := broker.Subscribe(userID)
+
+	if !broker.HasSubscribers(userID) {
+		t.Fatal("expected the user to have a subscriber")
+	}
+
+	broker.CloseAll()
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription to be closed")
+	}
+	if broker.HasSubscribers(userID) {
+		t.Error("expected no subscriber left")
+	}
+}
+
+func TestParseNotification(t *testing.T) {
+	userID := uuid.New()
+
+	id, parsedUserID, err := parseNotification("42:" + userID.String())
+	if err != nil || id != 42 || parsedUserID != userID {
+		t.Errorf("expected 42 and %s, got %d, %s (%v)", userID, id, parsedUserID, err)
+	}
+
+	for _, payload := range []string{"", "42", "x:" + userID.String(), "42:nope"} {
+		if _, _, err := parseNotification(payload); err == nil {
+			t.Errorf("%q: expected an error", payload)
+		}
+	}
+}
+
+func TestEncodeEphemeral(t *testing.T) {
+	event := Event{ID: 7, UserID: uuid.New(), Type: "typing", Payload: []byte(`{"conversation_id":"abc"}`)}
+
+	encoded, err := EncodeEphemeral(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Event
+	if err := json.Unmarshal([]byte(encoded), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Ephemeral() || decoded.UserID != event.UserID || string(decoded.Payload) != string(event.Payload) {
+		t.Errorf("expected the event back without its ID, got %+v", decoded)
+	}
+
+	event.Payload = []byte(`"` + strings.Repeat("x", 8000) + `"`)
+	if _, err := EncodeEphemeral(event); err == nil {
+		t.Error("expected an error for a payload over the notification limit")
+	}
+}
+
+func TestRecentIDs(t *testing.T) {
+	recent := NewRecentIDs(3)
+
+	for _, id := range []int64{5, 3, 4} {
+		if !recent.Add(id) {
+			t.Errorf("expected %d to be new", id)
+		}
+	}
+	if recent.Add(3) {
+		t.Error("expected 3 to be seen already, even though it's lower than the last ID")
+	}
+
+	// full, so the oldest ID (5) makes room for 6
+	if !recent.Add(6) {
+		t.Error("expected 6 to be new")
+	}
+	if !recent.Add(5) {
+		t.Error("expected 5 to be forgotten once the capacity was reached")
+	}
+	if recent.Add(6) {
+		t.Error("expected 6 to still be remembered")
+	}
+}
@@ -0,0 +1,122 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// Channel is the Postgres channel the stream_events trigger notifies, with "<id>:<user id>" payloads
+	Channel = "stream_events"
+	// EphemeralChannel carries whole ephemeral events, encoded with EncodeEphemeral
+	EphemeralChannel = "stream_ephemeral"
+)
+
+// EncodeEphemeral encodes an ephemeral event as a notification payload for EphemeralChannel.
+// Postgres caps payloads at 8000 bytes, so ephemeral events must stay small.
+func EncodeEphemeral(event Event) (string, error) {
+	event.ID = 0
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	if len(encoded) >= 8000 {
+		return "", fmt.Errorf("ephemeral event too large: %d bytes", len(encoded))
+	}
+	return string(encoded), nil
+}
+
+// Loader fetches a stored event by its ID
+type Loader func(ctx context.Context, id int64) (Event, error)
+
+// parseNotification reads the "<id>:<user id>" payload of a notification
+func parseNotification(payload string) (int64, uuid.UUID, error) {
+	idStr, userIDStr, ok := strings.Cut(payload, ":")
+	if !ok {
+		return 0, uuid.Nil, fmt.Errorf("invalid notification %q", payload)
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, uuid.Nil, fmt.Errorf("invalid notification %q: %w", payload, err)
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return 0, uuid.Nil, fmt.Errorf("invalid notification %q: %w", payload, err)
+	}
+
+	return id, userID, nil
+}
+
+// Listen publishes to the broker the events notified on Channel & EphemeralChannel, until the context is cancelled.
+// Only the events of users with a client connected here are loaded. When the connection is lost,
+// notifications may have been missed, so every subscription is closed and the clients resume from the stored events.
+func Listen(ctx context.Context, dbURL string, broker *Broker, load Loader) error {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("stream events listener: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	for _, channel := range []string{Channel, EphemeralChannel} {
+		if err := listener.Listen(channel); err != nil {
+			return fmt.Errorf("couldn't listen on %s: %w", channel, err)
+		}
+	}
+
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ping.C:
+			// a dead connection is noticed (and re-established) sooner
+			go listener.Ping()
+		case notification := <-listener.Notify:
+			// nil after a reconnection
+			if notification == nil {
+				broker.CloseAll()
+				continue
+			}
+
+			if notification.Channel == EphemeralChannel {
+				var event Event
+				if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+					log.Printf("invalid ephemeral event: %v", err)
+					continue
+				}
+				event.ID = 0
+				broker.Publish(event)
+				continue
+			}
+
+			id, userID, err := parseNotification(notification.Extra)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if !broker.HasSubscribers(userID) {
+				continue
+			}
+
+			event, err := load(ctx, id)
+			if err != nil {
+				log.Printf("couldn't load stream event %d: %v", id, err)
+				continue
+			}
+			broker.Publish(event)
+		}
+	}
+}
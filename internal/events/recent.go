@@ -0,0 +1,37 @@
+package events
+
+// RecentIDs remembers the last IDs seen, up to its capacity, so an event delivered twice is only sent once.
+// The IDs are assigned before the events are committed, so they can arrive out of order: a client can't
+// just skip the IDs below the last one it got.
+type RecentIDs struct {
+	seen  map[int64]struct{}
+	order []int64
+	next  int
+}
+
+func NewRecentIDs(capacity int) *RecentIDs {
+	capacity = max(capacity, 1)
+	return &RecentIDs{
+		seen:  make(map[int64]struct{}, capacity),
+		order: make([]int64, 0, capacity),
+	}
+}
+
+// Add records the ID, it returns false when the ID was already seen.
+// Once full, the oldest ID is forgotten to make room.
+func (r *RecentIDs) Add(id int64) bool {
+	if _, ok := r.seen[id]; ok {
+		return false
+	}
+
+	if len(r.order) < cap(r.order) {
+		r.order = append(r.order, id)
+	} else {
+		delete(r.seen, r.order[r.next])
+		r.order[r.next] = id
+		r.next = (r.next + 1) % len(r.order)
+	}
+	r.seen[id] = struct{}{}
+
+	return true
+}
@@ -0,0 +1,166 @@
+// Package jobs runs the background work the requests don't wait for (fan-outs, notifications, ...).
+// The jobs live in a Store (Postgres for the server), so a crash or a restart doesn't lose them, and every replica
+// runs them: a batch is claimed with a lease, the jobs of a worker that died run again once the lease is over.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrLeaseLost is returned when the job was claimed again after its lease ran out, the stale attempt must not
+// change anything
+var ErrLeaseLost = errors.New("job was claimed again after its lease")
+
+// Job is a claimed job, Attempts counts this run
+type Job struct {
+	ID       uuid.UUID
+	Kind     string
+	Payload  []byte
+	Attempts int
+}
+
+// Store keeps the jobs between their runs
+type Store interface {
+	// Claim leases up to limit jobs due at now until leaseUntil, counting an attempt for each of them
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Job, error)
+	// Retry schedules the next run of a failed job, ErrLeaseLost if it was claimed again meanwhile
+	Retry(ctx context.Context, job Job, runAt time.Time, lastError string) error
+	// Discard removes a job that failed its last attempt, ErrLeaseLost if it was claimed again meanwhile
+	Discard(ctx context.Context, job Job) error
+}
+
+// Handler does the work of a job and removes it from the store in the same transaction, so the work of a job is
+// committed once at most. It returns ErrLeaseLost, rolling the work back, when the job was claimed again meanwhile.
+type Handler func(ctx context.Context, job Job) error
+
+// leaseMargin is added to the timeout of a run, so a job is never claimed again while it can still be running
+const leaseMargin = time.Minute
+
+// Runner runs the due jobs of a store, replicas running at the same time split them
+type Runner struct {
+	store  Store
+	handle Handler
+
+	// BatchSize is the number of jobs claimed at once, they run concurrently
+	BatchSize int
+	// Timeout bounds a single run
+	Timeout time.Duration
+	// MaxAttempts is the number of runs before a failing job is given up
+	MaxAttempts int
+	// the retries back off exponentially between these delays
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+
+	now func() time.Time
+}
+
+// NewRunner returns a runner of the store's jobs with the default limits, handle does the work of every kind of job
+func NewRunner(store Store, handle Handler) *Runner {
+	return &Runner{
+		store:       store,
+		handle:      handle,
+		BatchSize:   20,
+		Timeout:     time.Second * 30,
+		MaxAttempts: 8,
+		BackoffBase: time.Second * 10,
+		BackoffMax:  time.Hour,
+		now:         time.Now,
+	}
+}
+
+// Backoff returns the delay before the next run of a job that failed its nth attempt
+func (r *Runner) Backoff(attempt int) time.Duration {
+	delay := r.BackoffBase
+	for i := 1; i < attempt && delay < r.BackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, r.BackoffMax)
+}
+
+// RunDue runs the jobs that are due, batch after batch
+func (r *Runner) RunDue(ctx context.Context) (int, error) {
+	ran := 0
+	for {
+		now := r.now()
+		jobs, err := r.store.Claim(ctx, now, now.Add(r.Timeout+leaseMargin), r.BatchSize)
+		if err != nil {
+			return ran, err
+		}
+
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.run(ctx, job)
+			}()
+		}
+		wg.Wait()
+
+		ran += len(jobs)
+		if len(jobs) < r.BatchSize {
+			return ran, nil
+		}
+	}
+}
+
+// Run runs RunDue every interval until the context is cancelled
+func (r *Runner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.RunDue(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("couldn't run the background jobs: %v", err)
+			}
+		}
+	}
+}
+
+// run runs one claimed job, then schedules its retry or gives it up when it failed
+func (r *Runner) run(ctx context.Context, job Job) {
+	err := r.handleJob(ctx, job)
+	switch {
+	case err == nil, errors.Is(err, ErrLeaseLost):
+		return
+	// cancelled by the shutdown, the lease runs out and another replica takes it
+	case ctx.Err() != nil:
+		return
+	case job.Attempts >= r.MaxAttempts:
+		log.Printf("giving up on %s job %s after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		if err := r.store.Discard(ctx, job); err != nil && !errors.Is(err, ErrLeaseLost) {
+			log.Printf("couldn't remove %s job %s: %v", job.Kind, job.ID, err)
+		}
+	default:
+		log.Printf("%s job %s failed (attempt %d): %v", job.Kind, job.ID, job.Attempts, err)
+		err := r.store.Retry(ctx, job, r.now().Add(r.Backoff(job.Attempts)), err.Error())
+		if err != nil && !errors.Is(err, ErrLeaseLost) {
+			log.Printf("couldn't schedule the retry of %s job %s: %v", job.Kind, job.ID, err)
+		}
+	}
+}
+
+// handleJob runs the handler within the timeout, a panic fails the job instead of the process
+func (r *Runner) handleJob(ctx context.Context, job Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	return r.handle(ctx, job)
+}
@@ -0,0 +1,313 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore is a Store with the semantics of the Postgres one: a claim pushes run_at back to the lease and
+// counts an attempt, the changes of an attempt only apply while the job is still claimed by it
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*storedJob
+}
+
+type storedJob struct {
+	job       Job
+	runAt     time.Time
+	lastError string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{jobs: map[uuid.UUID]*storedJob{}}
+}
+
+func (s *memoryStore) add(kind string, runAt time.Time) uuid.UUID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := uuid.New()
+	s.jobs[id] = &storedJob{job: Job{ID: id, Kind: kind}, runAt: runAt}
+	return id
+}
+
+func (s *memoryStore) get(id uuid.UUID) (storedJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.jobs[id]
+	if !ok {
+		return storedJob{}, false
+	}
+	return *stored, true
+}
+
+func (s *memoryStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []Job
+	for _, stored := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if stored.runAt.After(now) {
+			continue
+		}
+		stored.runAt = leaseUntil
+		stored.job.Attempts++
+		claimed = append(claimed, stored.job)
+	}
+	return claimed, nil
+}
+
+// claimedBy returns the stored job if this attempt still holds it, the caller holds the lock
+func (s *memoryStore) claimedBy(job Job) (*storedJob, error) {
+	stored, ok := s.jobs[job.ID]
+	if !ok || stored.job.Attempts != job.Attempts {
+		return nil, ErrLeaseLost
+	}
+	return stored, nil
+}
+
+// complete is what a Handler does in its transaction once the work is done
+func (s *memoryStore) complete(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.claimedBy(job); err != nil {
+		return err
+	}
+	delete(s.jobs, job.ID)
+	return nil
+}
+
+func (s *memoryStore) Retry(ctx context.Context, job Job, runAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.claimedBy(job)
+	if err != nil {
+		return err
+	}
+	stored.runAt = runAt
+	stored.lastError = lastError
+	return nil
+}
+
+func (s *memoryStore) Discard(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.claimedBy(job); err != nil {
+		return err
+	}
+	delete(s.jobs, job.ID)
+	return nil
+}
+
+// clock is a settable time for the runner
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestRunner(store *memoryStore, handle Handler) (*Runner, *clock) {
+	c := &clock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	runner := NewRunner(store, handle)
+	runner.now = c.Now
+	return runner, c
+}
+
+func TestBackoff(t *testing.T) {
+	runner := NewRunner(newMemoryStore(), nil)
+	runner.BackoffBase = time.Second * 10
+	runner.BackoffMax = time.Minute * 5
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, time.Second * 10},
+		{2, time.Second * 20},
+		{3, time.Second * 40},
+		{4, time.Second * 80},
+		{5, time.Second * 160},
+		{6, time.Minute * 5},
+		{50, time.Minute * 5},
+	}
+
+	for _, tc := range tests {
+		if got := runner.Backoff(tc.attempt); got != tc.expected {
+			t.Errorf("attempt %d: expected %s, got %s", tc.attempt, tc.expected, got)
+		}
+	}
+}
+
+func TestRunDueCompletesJobs(t *testing.T) {
+	store := newMemoryStore()
+	runner, c := newTestRunner(store, func(ctx context.Context, job Job) error {
+		return store.complete(job)
+	})
+	first := store.add("a", c.Now())
+	second := store.add("b", c.Now())
+	later := store.add("c", c.Now().Add(time.Hour))
+
+	ran, err := runner.RunDue(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ran != 2 {
+		t.Errorf("expected the 2 due jobs to run, got %d", ran)
+	}
+	for _, id := range []uuid.UUID{first, second} {
+		if _, ok := store.get(id); ok {
+			t.Errorf("expected job %s to be removed", id)
+		}
+	}
+	if _, ok := store.get(later); !ok {
+		t.Errorf("expected the job that isn't due yet to be kept")
+	}
+}
+
+func TestRunDueRetriesThenGivesUp(t *testing.T) {
+	store := newMemoryStore()
+	failure := errors.New("receiver is down")
+	runner, c := newTestRunner(store, func(ctx context.Context, job Job) error {
+		return failure
+	})
+	runner.MaxAttempts = 3
+	id := store.add("flaky", c.Now())
+
+	for attempt := 1; attempt < runner.MaxAttempts; attempt++ {
+		if _, err := runner.RunDue(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stored, ok := store.get(id)
+		if !ok {
+			t.Fatalf("attempt %d: expected the job to be kept for a retry", attempt)
+		}
+		if stored.job.Attempts != attempt {
+			t.Errorf("expected %d attempts, got %d", attempt, stored.job.Attempts)
+		}
+		if expected := c.Now().Add(runner.Backoff(attempt)); !stored.runAt.Equal(expected) {
+			t.Errorf("attempt %d: expected the retry at %s, got %s", attempt, expected, stored.runAt)
+		}
+		if stored.lastError != failure.Error() {
+			t.Errorf("expected the last error to be stored, got %q", stored.lastError)
+		}
+
+		// not due before its backoff
+		if ran, _ := runner.RunDue(context.Background()); ran != 0 {
+			t.Errorf("attempt %d: expected no run during the backoff, got %d", attempt, ran)
+		}
+		c.Advance(runner.Backoff(attempt))
+	}
+
+	if _, err := runner.RunDue(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := store.get(id); ok {
+		t.Errorf("expected the job to be given up after %d attempts", runner.MaxAttempts)
+	}
+}
+
+func TestRunDueRecoversPanics(t *testing.T) {
+	store := newMemoryStore()
+	runner, c := newTestRunner(store, func(ctx context.Context, job Job) error {
+		panic("boom")
+	})
+	id := store.add("panics", c.Now())
+
+	if _, err := runner.RunDue(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored, ok := store.get(id)
+	if !ok {
+		t.Fatalf("expected the job to be kept for a retry")
+	}
+	if stored.lastError != "panic: boom" {
+		t.Errorf("expected the panic as the last error, got %q", stored.lastError)
+	}
+}
+
+func TestExpiredLeaseIsClaimedAgain(t *testing.T) {
+	store := newMemoryStore()
+	var handled []Job
+	runner, c := newTestRunner(store, func(ctx context.Context, job Job) error {
+		handled = append(handled, job)
+		return store.complete(job)
+	})
+	id := store.add("fan-out", c.Now())
+
+	// a worker claims the job, then dies without finishing it
+	stale, err := store.Claim(context.Background(), c.Now(), c.Now().Add(runner.Timeout+leaseMargin), 1)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("expected to claim the job, got %v (%v)", stale, err)
+	}
+
+	// still leased
+	c.Advance(runner.Timeout)
+	if ran, _ := runner.RunDue(context.Background()); ran != 0 {
+		t.Errorf("expected the leased job not to run, got %d runs", ran)
+	}
+
+	c.Advance(leaseMargin)
+	if ran, _ := runner.RunDue(context.Background()); ran != 1 {
+		t.Fatalf("expected the job to run once its lease is over, got %d runs", ran)
+	}
+	if len(handled) != 1 || handled[0].Attempts != 2 {
+		t.Fatalf("expected the second attempt to run, got %+v", handled)
+	}
+	if _, ok := store.get(id); ok {
+		t.Errorf("expected the job to be removed")
+	}
+
+	// the stale worker coming back can't change anything anymore
+	if err := store.complete(stale[0]); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost for the stale attempt, got %v", err)
+	}
+}
+
+func TestStaleAttemptDoesntRescheduleTheJob(t *testing.T) {
+	store := newMemoryStore()
+	runner, c := newTestRunner(store, nil)
+	id := store.add("slow", c.Now())
+
+	stale, _ := store.Claim(context.Background(), c.Now(), c.Now().Add(time.Minute), 1)
+	c.Advance(time.Minute)
+	current, _ := store.Claim(context.Background(), c.Now(), c.Now().Add(time.Minute), 1)
+	if len(stale) != 1 || len(current) != 1 {
+		t.Fatalf("expected both claims to get the job")
+	}
+
+	// the stale attempt fails late, the retry it schedules would cut the current lease short
+	runner.handle = func(ctx context.Context, job Job) error { return errors.New("timeout") }
+	runner.run(context.Background(), stale[0])
+
+	stored, _ := store.get(id)
+	if !stored.runAt.Equal(c.Now().Add(time.Minute)) {
+		t.Errorf("expected the current lease to be kept, got run_at %s", stored.runAt)
+	}
+	if err := store.complete(current[0]); err != nil {
+		t.Errorf("expected the current attempt to complete the job, got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+// LogMailer only logs the messages, for development setups without an SMTP server
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, message Message) error {
+	log.Printf("email to %s: %s\n%s", message.To, message.Subject, message.Text)
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package mailer sends the emails of the server (activity digests, ...) through an SMTP server,
+// or only logs them when no mail delivery is set up.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	// [Optional] one-click unsubscribe link, sent as the List-Unsubscribe header
+	UnsubscribeURL string
+}
+
+// Mailer delivers messages, Send returns once the message was handed over
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// build renders the message in the RFC 5322 format.
+// The header values are user provided (addresses, links), a line break in one of them would inject headers.
+func build(from string, message Message, date time.Time) ([]byte, error) {
+	for _, value := range []string{from, message.To, message.Subject, message.UnsubscribeURL} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid line break in a header value")
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	if message.UnsubscribeURL != "" {
+		fmt.Fprintf(&buf, "List-Unsubscribe: <%s>\r\n", message.UnsubscribeURL)
+		buf.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+
+	// SMTP lines end with CRLF
+	text := strings.ReplaceAll(message.Text, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	if !strings.HasSuffix(text, "\n") {
+		buf.WriteString("\r\n")
+	}
+
+	return buf.Bytes(), nil
+}
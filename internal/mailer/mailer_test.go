@@ -0,0 +1,130 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	message := Message{
+		To:             "user@example.com",
+		Subject:        "Your daily digest",
+		Text:           "line one\nline two",
+		UnsubscribeURL: "https://chirpy.example/api/digest/unsubscribe?token=abc",
+	}
+
+	body, err := build("Chirpy <noreply@chirpy.example>", message, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, expected := range []string{
+		"From: Chirpy <noreply@chirpy.example>\r\n",
+		"To: user@example.com\r\n",
+		"Subject: Your daily digest\r\n",
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+		"List-Unsubscribe: <https://chirpy.example/api/digest/unsubscribe?token=abc>\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected %q in:\n%s", expected, body)
+		}
+	}
+}
+
+func TestBuildRejectsHeaderInjection(t *testing.T) {
+	_, err := build("noreply@chirpy.example", Message{
+		To:      "user@example.com\r\nBcc: everyone@example.com",
+		Subject: "hi",
+	}, time.Now())
+	if err == nil {
+		t.Error("expected a line break in a header to be rejected")
+	}
+}
+
+// fakeSMTPServer accepts one session and returns the received message data
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("couldn't listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ready")
+
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	mailer := SMTPMailer{Addr: addr, From: "noreply@chirpy.example"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	err := mailer.Send(ctx, Message{To: "user@example.com", Subject: "hello", Text: "hi there"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "Subject: hello\r\n") || !strings.Contains(data, "hi there") {
+			t.Errorf("unexpected message:\n%s", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the server didn't receive the message")
+	}
+}
@@ -0,0 +1,73 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedGIF = errors.New("couldn't decode the image: malformed GIF")
+
+// gifFrames walks the blocks of a GIF without decoding them, and returns its number of frames and the
+// sum of their pixels, which is what decoding every frame costs
+func gifFrames(data []byte) (frames int, pixels int, err error) {
+	// header (GIF87a / GIF89a), then the logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, errMalformedGIF
+	}
+	offset := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		offset += 3 << ((flags & 0x07) + 1)
+	}
+
+	for offset < len(data) {
+		switch data[offset] {
+		case 0x21: // extension => label, then sub-blocks
+			offset, err = skipGIFSubBlocks(data, offset+2)
+			if err != nil {
+				return 0, 0, err
+			}
+		case 0x2C: // image descriptor => position, size & flags, an optional local color table, then the LZW data
+			if offset+10 > len(data) {
+				return 0, 0, errMalformedGIF
+			}
+			width := int(binary.LittleEndian.Uint16(data[offset+5:]))
+			height := int(binary.LittleEndian.Uint16(data[offset+7:]))
+			flags := data[offset+9]
+			offset += 10
+			if flags&0x80 != 0 {
+				offset += 3 << ((flags & 0x07) + 1)
+			}
+
+			frames++
+			pixels += width * height
+
+			// skip the LZW minimum code size
+			offset, err = skipGIFSubBlocks(data, offset+1)
+			if err != nil {
+				return 0, 0, err
+			}
+		case 0x3B: // trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, errMalformedGIF
+		}
+	}
+
+	// the trailer is missing, the decoder stops at the end of the data all the same
+	return frames, pixels, nil
+}
+
+// skipGIFSubBlocks returns the offset after the sub-blocks starting at offset, they end with an empty block
+func skipGIFSubBlocks(data []byte, offset int) (int, error) {
+	for {
+		if offset >= len(data) {
+			return 0, errMalformedGIF
+		}
+		size := int(data[offset])
+		offset++
+		if size == 0 {
+			return offset, nil
+		}
+		offset += size
+	}
+}
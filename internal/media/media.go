@@ -0,0 +1,310 @@
+// Package media validates and normalizes uploaded images.
+// Uploads are sniffed (the declared content type isn't trusted), decoded and re-encoded, which drops
+// EXIF and any other metadata, after applying the EXIF orientation so photos keep the right side up.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, use JPEG, PNG, GIF or WebP")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+	ErrTooManyFrames   = errors.New("animation has too many frames")
+)
+
+const jpegQuality = 85
+
+type Options struct {
+	// MaxPixels caps width * height, checked before decoding so huge images can't exhaust the memory
+	MaxPixels int
+	// MaxFrames & MaxAnimationPixels cap the frames of an animated GIF, and their pixels summed up,
+	// also checked before decoding since every frame is decoded (0 means no limit)
+	MaxFrames          int
+	MaxAnimationPixels int
+	// ThumbnailSize is the box the thumbnail fits in, keeping the aspect ratio
+	ThumbnailSize int
+}
+
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+
+	ThumbnailContentType string
+	Thumbnail            []byte
+}
+
+// Sniff returns the image content type detected from the data, ErrUnsupportedType for anything else
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return contentType, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// Process re-encodes the uploaded image without its metadata, and generates its thumbnail
+func Process(data []byte, options Options) (Image, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if err := checkDimensions(data, options.MaxPixels); err != nil {
+		return Image{}, err
+	}
+
+	// GIFs keep their animation, re-encoding drops their comment & application extensions
+	if contentType == "image/gif" {
+		return processGIF(data, options)
+	}
+
+	img, err := decode(data, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	result := Image{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+
+	result.ContentType, result.Data, err = encode(img)
+	if err != nil {
+		return Image{}, err
+	}
+
+	result.ThumbnailContentType, result.Thumbnail, err = encode(Fit(img, options.ThumbnailSize))
+	if err != nil {
+		return Image{}, err
+	}
+
+	return result, nil
+}
+
+// Avatar is a square image in several sizes, all encoded with the same content type
+type Avatar struct {
+	ContentType string
+	Sizes       map[int][]byte
+}
+
+// ProcessAvatar crops the center square of the image and scales it to each size.
+// Animated GIFs keep their first frame only.
+func ProcessAvatar(data []byte, options Options, sizes []int) (Avatar, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return Avatar{}, err
+	}
+
+	if err := checkDimensions(data, options.MaxPixels); err != nil {
+		return Avatar{}, err
+	}
+
+	img, err := decode(data, contentType)
+	if err != nil {
+		return Avatar{}, err
+	}
+
+	avatar := Avatar{Sizes: map[int][]byte{}}
+	opaque := isOpaque(img)
+	for _, size := range sizes {
+		avatar.ContentType, avatar.Sizes[size], err = encodeAs(Square(img, size), opaque)
+		if err != nil {
+			return Avatar{}, err
+		}
+	}
+
+	return avatar, nil
+}
+
+// decode decodes the image, turned upright when it's a JPEG with an EXIF orientation
+func decode(data []byte, contentType string) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode the image: %w", err)
+	}
+
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	return img, nil
+}
+
+func checkDimensions(data []byte, maxPixels int) error {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("couldn't decode the image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return errors.New("image has no pixels")
+	}
+	if maxPixels > 0 && config.Width*config.Height > maxPixels {
+		return ErrTooManyPixels
+	}
+	return nil
+}
+
+func processGIF(data []byte, options Options) (Image, error) {
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		return Image{}, err
+	}
+	if (options.MaxFrames > 0 && frames > options.MaxFrames) || (options.MaxAnimationPixels > 0 && pixels > options.MaxAnimationPixels) {
+		return Image{}, ErrTooManyFrames
+	}
+
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("couldn't decode the image: %w", err)
+	}
+
+	var output bytes.Buffer
+	if err := gif.EncodeAll(&output, &gif.GIF{
+		Image:     animation.Image,
+		Delay:     animation.Delay,
+		LoopCount: animation.LoopCount,
+		Disposal:  animation.Disposal,
+		Config:    animation.Config,
+	}); err != nil {
+		return Image{}, fmt.Errorf("couldn't encode the image: %w", err)
+	}
+
+	// the thumbnail is the first frame, drawn on the full canvas
+	firstFrame := image.NewNRGBA(image.Rect(0, 0, animation.Config.Width, animation.Config.Height))
+	draw.Draw(firstFrame, animation.Image[0].Bounds(), animation.Image[0], animation.Image[0].Bounds().Min, draw.Over)
+
+	thumbnailContentType, thumbnail, err := encode(Fit(firstFrame, options.ThumbnailSize))
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		ContentType:          "image/gif",
+		Data:                 output.Bytes(),
+		Width:                animation.Config.Width,
+		Height:               animation.Config.Height,
+		ThumbnailContentType: thumbnailContentType,
+		Thumbnail:            thumbnail,
+	}, nil
+}
+
+// encode writes opaque images as JPEG, and the ones with transparency as PNG
+func encode(img image.Image) (string, []byte, error) {
+	return encodeAs(img, isOpaque(img))
+}
+
+func encodeAs(img image.Image, opaque bool) (string, []byte, error) {
+	var output bytes.Buffer
+
+	if opaque {
+		if err := jpeg.Encode(&output, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return "", nil, fmt.Errorf("couldn't encode the image: %w", err)
+		}
+		return "image/jpeg", output.Bytes(), nil
+	}
+
+	if err := png.Encode(&output, img); err != nil {
+		return "", nil, fmt.Errorf("couldn't encode the image: %w", err)
+	}
+	return "image/png", output.Bytes(), nil
+}
+
+func isOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Fit scales the image down to fit in a size x size box, smaller images are only copied
+func Fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if size > 0 && (width > size || height > size) {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Square crops the center square of the image, then scales it to size x size
+func Square(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, image.Rect(x0, y0, x0+side, y0+side), draw.Src, nil)
+	return dst
+}
+
+// applyOrientation turns the image upright according to the EXIF orientation (1 to 8)
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// orientations 5 to 8 swap the width and the height
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var srcX, srcY int
+			switch orientation {
+			case 2: // mirrored horizontally
+				srcX, srcY = width-1-x, y
+			case 3: // rotated 180
+				srcX, srcY = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				srcX, srcY = x, height-1-y
+			case 5: // transposed
+				srcX, srcY = y, x
+			case 6: // rotated 90 clockwise
+				srcX, srcY = y, height-1-x
+			case 7: // transversed
+				srcX, srcY = width-1-y, height-1-x
+			case 8: // rotated 90 counter-clockwise
+				srcX, srcY = width-1-y, x
+			}
+			dst.Set(x, y, color.NRGBAModel.Convert(img.At(bounds.Min.X+srcX, bounds.Min.Y+srcY)))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,209 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func solidImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// jpegWithOrientation encodes the image as a JPEG carrying an EXIF segment with the given orientation
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// little-endian TIFF header, then an IFD with the orientation as its only entry
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, exifOrientationTag)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := encoded.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestProcessStripsExifAndRotates(t *testing.T) {
+	data := jpegWithOrientation(t, solidImage(40, 20, color.White), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("expected the test image to carry orientation 6, got %d", jpegOrientation(data))
+	}
+
+	result, err := Process(data, Options{ThumbnailSize: 10})
+	if err != nil {
+		t.Fatalf("couldn't process the image: %v", err)
+	}
+
+	if result.ContentType != "image/jpeg" {
+		t.Errorf("expected image/jpeg, got %s", result.ContentType)
+	}
+	if bytes.Contains(result.Data, []byte("Exif")) {
+		t.Error("expected the EXIF segment to be stripped")
+	}
+	if result.Width != 20 || result.Height != 40 {
+		t.Errorf("expected the image to be rotated to 20x40, got %dx%d", result.Width, result.Height)
+	}
+
+	thumbnail, _, err := image.Decode(bytes.NewReader(result.Thumbnail))
+	if err != nil {
+		t.Fatalf("couldn't decode the thumbnail: %v", err)
+	}
+	if thumbnail.Bounds().Dx() != 5 || thumbnail.Bounds().Dy() != 10 {
+		t.Errorf("expected a 5x10 thumbnail, got %v", thumbnail.Bounds())
+	}
+}
+
+func TestProcessKeepsTransparency(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, solidImage(8, 8, color.NRGBA{R: 255, A: 128})); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Process(encoded.Bytes(), Options{ThumbnailSize: 4})
+	if err != nil {
+		t.Fatalf("couldn't process the image: %v", err)
+	}
+	if result.ContentType != "image/png" || result.ThumbnailContentType != "image/png" {
+		t.Errorf("expected transparent images to stay PNG, got %s / %s", result.ContentType, result.ThumbnailContentType)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), Options{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, solidImage(100, 100, color.Black)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Process(encoded.Bytes(), Options{MaxPixels: 5000}); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("expected ErrTooManyPixels, got %v", err)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// a 2x1 image, red on the left and blue on the right
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	img.Set(1, 0, color.NRGBA{B: 255, A: 255})
+
+	red := color.NRGBA{R: 255, A: 255}
+
+	tests := map[int]image.Point{
+		1: {0, 0},
+		2: {1, 0},
+		3: {1, 0},
+		6: {0, 0},
+		8: {0, 1},
+	}
+
+	for orientation, redAt := range tests {
+		rotated := applyOrientation(img, orientation)
+		if got := color.NRGBAModel.Convert(rotated.At(redAt.X, redAt.Y)); got != red {
+			t.Errorf("orientation %d: expected red at %v, got %v", orientation, redAt, got)
+		}
+	}
+}
+
+func TestProcessAvatar(t *testing.T) {
+	// a wide image, the avatar keeps its center
+	img := solidImage(300, 100, color.Black)
+	for y := 0; y < 100; y++ {
+		for x := 100; x < 200; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+
+	avatar, err := ProcessAvatar(encoded.Bytes(), Options{}, []int{48, 128})
+	if err != nil {
+		t.Fatalf("couldn't process the avatar: %v", err)
+	}
+	if avatar.ContentType != "image/jpeg" {
+		t.Errorf("expected an opaque avatar to be a JPEG, got %s", avatar.ContentType)
+	}
+
+	for _, size := range []int{48, 128} {
+		decoded, _, err := image.Decode(bytes.NewReader(avatar.Sizes[size]))
+		if err != nil {
+			t.Fatalf("couldn't decode the %d avatar: %v", size, err)
+		}
+		if decoded.Bounds().Dx() != size || decoded.Bounds().Dy() != size {
+			t.Errorf("expected a %dx%d avatar, got %v", size, size, decoded.Bounds())
+		}
+		if r, g, b, _ := decoded.At(0, 0).RGBA(); r < 0xf000 || g < 0xf000 || b < 0xf000 {
+			t.Errorf("expected the %d avatar to be cropped to the white center", size)
+		}
+	}
+}
+
+func animatedGIF(t *testing.T, frames, width, height int) []byte {
+	palette := color.Palette{color.Black, color.White}
+	animation := &gif.GIF{}
+	for range frames {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, width, height), palette))
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	var encoded bytes.Buffer
+	if err := gif.EncodeAll(&encoded, animation); err != nil {
+		t.Fatal(err)
+	}
+	return encoded.Bytes()
+}
+
+func TestGIFFrames(t *testing.T) {
+	frames, pixels, err := gifFrames(animatedGIF(t, 3, 20, 10))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if frames != 3 || pixels != 600 {
+		t.Errorf("expected 3 frames & 600 pixels, got %d frames & %d pixels", frames, pixels)
+	}
+
+	if _, _, err := gifFrames([]byte("GIF89a")); err == nil {
+		t.Error("expected a truncated GIF to be rejected")
+	}
+}
+
+func TestProcessRejectsLongAnimations(t *testing.T) {
+	data := animatedGIF(t, 5, 20, 20)
+
+	if _, err := Process(data, Options{MaxFrames: 5, MaxAnimationPixels: 2000}); err != nil {
+		t.Errorf("expected the animation to fit the limits, got %v", err)
+	}
+	if _, err := Process(data, Options{MaxFrames: 4}); !errors.Is(err, ErrTooManyFrames) {
+		t.Errorf("expected ErrTooManyFrames for the frame count, got %v", err)
+	}
+	if _, err := Process(data, Options{MaxAnimationPixels: 1999}); !errors.Is(err, ErrTooManyFrames) {
+		t.Errorf("expected ErrTooManyFrames for the total pixels, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+// Package storage keeps uploaded files (blobs) on the local filesystem or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores immutable blobs by key, keys are slash separated paths like "media/<id>/original"
+type BlobStore interface {
+	Put(ctx context.Context, key string, contentType string, body []byte) error
+	// Get returns the blob content, ErrNotFound when there's no blob with that key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob, deleting a missing blob isn't an error
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey rejects keys that could escape the store root (or bucket prefix)
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+// Package textlength measures chirps the way a reader sees them: in grapheme clusters (user-perceived
+// characters) rather than bytes or runes, so an emoji or an accented letter counts as one character.
+package textlength
+
+import (
+	"regexp"
+
+	"github.com/rivo/uniseg"
+)
+
+// urlPattern matches http(s) links, trailing punctuation is left out since it usually ends the sentence
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://\S*[^\s.,!?;:)\]'"]`)
+
+type Counter struct {
+	// URLLength is the length every link counts for, whatever its actual length (0 counts it as is)
+	URLLength int
+}
+
+// Count returns the length of the text in grapheme clusters, with links weighted to URLLength
+func (c Counter) Count(text string) int {
+	if c.URLLength <= 0 {
+		return uniseg.GraphemeClusterCount(text)
+	}
+
+	length := 0
+	cursor := 0
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		length += uniseg.GraphemeClusterCount(text[cursor:loc[0]]) + c.URLLength
+		cursor = loc[1]
+	}
+	length += uniseg.GraphemeClusterCount(text[cursor:])
+
+	return length
+}
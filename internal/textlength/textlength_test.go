@@ -0,0 +1,49 @@
+package textlength
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountGraphemes(t *testing.T) {
+	counter := Counter{}
+
+	tests := map[string]int{
+		"":                       0,
+		"hello":                  5,
+		"مرحبا بالعالم":          13,
+		"こんにちは":                  5,
+		"🐦":                      1,
+		"👍🏽":                     1, // skin tone modifier
+		"👨‍👩‍👧‍👦":                1, // ZWJ sequence
+		"🇪🇬":                     1, // flag
+		"é":                     1, // combining accent
+		strings.Repeat("🚀", 140): 140,
+	}
+
+	for text, expected := range tests {
+		if got := counter.Count(text); got != expected {
+			t.Errorf("%q: expected %d, got %d", text, expected, got)
+		}
+	}
+}
+
+func TestCountURLs(t *testing.T) {
+	counter := Counter{URLLength: 23}
+
+	tests := map[string]int{
+		"https://example.com": 23,
+		"see https://example.com/a/very/long/path?with=query&and=more#fragment": 4 + 23,
+		"HTTP://EXAMPLE.COM.":                    23 + 1,
+		"(https://example.com)":                  1 + 23 + 1,
+		"two links http://a.io and https://b.io": 10 + 23 + 5 + 23,
+		"not a link: example.com":                23,
+		"https://":                               8,
+	}
+
+	for text, expected := range tests {
+		if got := counter.Count(text); got != expected {
+			t.Errorf("%q: expected %d, got %d", text, expected, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// maxResponseBytes caps the response body kept in the delivery log
+const maxResponseBytes = 4096
+
+var ErrForbiddenAddress = errors.New("webhook URL resolves to a private address")
+
+// Request is one delivery attempt
+type Request struct {
+	URL        string
+	Secret     []byte
+	Event      string
+	DeliveryID string
+	Body       []byte
+}
+
+// Response is what the receiver answered, Body is truncated
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// Sender posts the signed webhooks
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender returns a sender whose requests time out after timeout.
+// Unless allowPrivate is set, it refuses to connect to loopback, private and link-local addresses,
+// checked on the resolved address so a DNS name can't point it at the internal network.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !publicIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		}
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConnsPerHost: 2,
+			},
+			// a redirect would skip the receiver's signature check, and could lead anywhere
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// ValidateURL checks the URL a subscription delivers to, the addresses are checked again on every delivery
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("invalid webhook URL, it must be an absolute http(s) URL")
+	}
+	if parsed.User != nil {
+		return fmt.Errorf("invalid webhook URL, credentials aren't allowed in it")
+	}
+	return nil
+}
+
+// Send posts the signed body, a non-2xx answer is returned as a response, not an error
+func (s *Sender) Send(ctx context.Context, request Request) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Body))
+	if err != nil {
+		return Response{}, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, request.Event)
+	req.Header.Set(DeliveryHeader, request.DeliveryID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(request.Secret, now, request.Body))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	return Response{StatusCode: res.StatusCode, Body: string(body)}, nil
+}
+
+// Succeeded reports whether the receiver accepted the delivery
+func (r Response) Succeeded() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Backoff returns the wait before the next attempt after `attempts` failed ones: base, 2*base, 4*base, ... up to max
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= max {
+			return max
+		}
+	}
+	return min(wait, max)
+}
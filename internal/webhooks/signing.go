@@ -0,0 +1,31 @@
+// Package webhooks signs and delivers the webhooks the server sends to third parties,
+// and verifies the signed ones it receives (Polka).
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+const (
+	// TimestampHeader carries the unix time the payload was signed at
+	TimestampHeader = "Chirpy-Timestamp"
+	// SignatureHeader carries the hex HMAC-SHA256 of the timestamp, a dot and the raw body
+	SignatureHeader = "Chirpy-Signature"
+	// EventHeader carries the event type, DeliveryHeader the delivery ID (the same across retries)
+	EventHeader    = "Chirpy-Event"
+	DeliveryHeader = "Chirpy-Delivery"
+)
+
+// Sign returns the hex HMAC-SHA256 of "<unix timestamp>.<body>".
+// Signing the timestamp along with the body keeps a captured request from being replayed later.
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature")
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	// ErrStaleTimestamp means the request was signed outside the replay window, it may be a replayed capture
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside the replay window")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Verify checks a signature made by Sign, for the incoming webhooks.
+// timestamp is the unix time header, it must be within window of now in either direction (clock skew).
+// Any of the secrets may match, so a secret can be rotated with the old and the new one active at once.
+// The signatures are compared in constant time.
+func Verify(secrets [][]byte, timestamp, signature string, body []byte, now time.Time, window time.Duration) error {
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	signedAt := time.Unix(unix, 0)
+	if signedAt.Before(now.Add(-window)) || signedAt.After(now.Add(window)) {
+		return ErrStaleTimestamp
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	for _, secret := range secrets {
+		if len(secret) == 0 {
+			continue
+		}
+		expected, _ := hex.DecodeString(Sign(secret, signedAt, body))
+		if hmac.Equal(given, expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
@@ -1,14 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/MeYo0o/chirpy_server/internal/auth"
+	"github.com/MeYo0o/chirpy_server/internal/contentfilter"
 	"github.com/MeYo0o/chirpy_server/internal/database"
+	"github.com/MeYo0o/chirpy_server/internal/events"
+	"github.com/MeYo0o/chirpy_server/internal/jobs"
+	"github.com/MeYo0o/chirpy_server/internal/mailer"
+	"github.com/MeYo0o/chirpy_server/internal/storage"
+	"github.com/MeYo0o/chirpy_server/internal/textlength"
+	"github.com/MeYo0o/chirpy_server/internal/webhooks"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -16,15 +34,62 @@ import (
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
 	jwtSecret      string
 	polkaKey       string
+	// Polka webhook signing => current secret, and the previous one while a rotation is under way
+	polkaSecrets          [][]byte
+	polkaSignatureWindow  time.Duration
+	polkaRequireSignature bool
+	// hashes new passwords with the configured algorithm, and still verifies the older ones
+	passwords      *auth.PasswordManager
+	passwordPolicy auth.PasswordPolicy
+	// login brute-force protection, tracked per account and per client IP
+	accountThrottle *auth.LoginThrottle
+	ipThrottle      *auth.LoginThrottle
+	// masks, rejects or flags chirps, its rules can change at runtime
+	contentFilter *contentfilter.Filter
+	// chirp length limits per tier, counted in grapheme clusters with links weighted to a fixed length
+	chirpCounter      textlength.Counter
+	chirpMaxLength    int
+	chirpMaxLengthRed int
+	// uploaded images, stored on the local filesystem or in an S3-compatible bucket
+	blobs          storage.BlobStore
+	mediaMaxBytes  int64
+	mediaMaxPixels int
+	avatarMaxBytes int64
+	// animated GIFs => every frame is decoded, so their frames & total pixels are capped too
+	mediaMaxFrames          int
+	mediaMaxAnimationPixels int
+	// prefix of the media URLs in the responses, empty for URLs relative to this server
+	mediaBaseURL string
+	// soft-deleted chirps can be restored by their author during the window, and are purged after the retention
+	chirpRestoreWindow time.Duration
+	chirpRetention     time.Duration
+	// pushes the users' events to their connected streams, fed by every replica through Postgres LISTEN/NOTIFY
+	events          *events.Broker
+	streamHeartbeat time.Duration
+	// WebSocket connection limits & keepalive
+	wsLimiter      *wsLimiter
+	wsPingInterval time.Duration
+	// sends the emails (activity digests), through SMTP or only to the logs
+	mailer mailer.Mailer
+	// public URL of this server, for the links in the emails
+	appBaseURL string
+	// sends the outbound webhooks, each attempt times out after webhookTimeout
+	webhookSender  *webhooks.Sender
+	webhookTimeout time.Duration
 }
 
 func main() {
 	// environment loading (.env)
 	godotenv.Load()
 
+	// cancelled on Ctrl+C / SIGTERM => stops the background workers and shuts the server down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Get the current platform
 	platform := os.Getenv("PLATFORM")
 
@@ -46,11 +111,125 @@ func main() {
 	// POLKA Key => Payment Gateway
 	polkaKey := os.Getenv("POLKA_KEY")
 
+	// Polka webhook signing secrets => the previous one stays valid during a rotation, until Polka only signs with the new one
+	polkaSecrets := [][]byte{}
+	for _, key := range []string{"POLKA_WEBHOOK_SECRET", "POLKA_WEBHOOK_SECRET_PREVIOUS"} {
+		if secret := os.Getenv(key); secret != "" {
+			polkaSecrets = append(polkaSecrets, []byte(secret))
+		}
+	}
+
+	// Admins => comma separated user IDs, promoted at startup. An email can't be trusted for this, it's never verified
+	adminUserIDs, err := parseUUIDList(os.Getenv("ADMIN_USER_IDS"))
+	if err != nil {
+		log.Fatalln("invalid ADMIN_USER_IDS:", err)
+	}
+
+	// Password hashing => argon2id by default, bcrypt hashes keep working and get upgraded on login
+	passwords := newPasswordManager(envString("PASSWORD_HASHER", "argon2id"))
+
+	// Password policy => length limits, plus an optional local breached-passwords corpus
+	passwordPolicy := auth.PasswordPolicy{
+		MinLength: envInt("PASSWORD_MIN_LENGTH", 8),
+		MaxBytes:  envInt("PASSWORD_MAX_BYTES", 72),
+	}
+	if breachCorpusPath := os.Getenv("BREACHED_PASSWORDS_PATH"); breachCorpusPath != "" {
+		breachCorpus, err := auth.LoadBreachCorpus(breachCorpusPath)
+		if err != nil {
+			log.Fatalln("couldn't load the breached passwords corpus:", err)
+		}
+		passwordPolicy.Breached = breachCorpus
+	}
+
+	// Content filter => rules from the DB (managed by the admins) and an optional file, reloaded periodically
+	contentFilter := newContentFilter(dbQueries, os.Getenv("CONTENT_FILTER_FILE"))
+	if err := contentFilter.Reload(ctx); err != nil {
+		log.Fatalln(err)
+	}
+	go reloadContentFilterEvery(ctx, contentFilter, envDuration("CONTENT_FILTER_RELOAD_INTERVAL", time.Minute))
+
+	// Media storage => local directory by default, or an S3-compatible bucket (AWS, MinIO, ...)
+	blobs, err := newBlobStore(envString("STORAGE_BACKEND", "local"))
+	if err != nil {
+		log.Fatalln("couldn't set up the media storage:", err)
+	}
+
+	webhookTimeout := envDuration("WEBHOOK_TIMEOUT", time.Second*10)
+
+	mailSender, err := newMailer(envString("MAILER", "log"))
+	if err != nil {
+		log.Fatalln("couldn't set up the mailer:", err)
+	}
+
 	cfg := apiConfig{
-		db:        dbQueries,
-		platform:  platform,
-		jwtSecret: jwtSecret,
-		polkaKey:  polkaKey,
+		db:             dbQueries,
+		dbConn:         db,
+		platform:       platform,
+		jwtSecret:      jwtSecret,
+		polkaKey:       polkaKey,
+		passwords:      passwords,
+		passwordPolicy: passwordPolicy,
+		// signed Polka webhooks, replayed ones are refused once the window is over
+		polkaSecrets:          polkaSecrets,
+		polkaSignatureWindow:  envDuration("POLKA_SIGNATURE_WINDOW", time.Minute*5),
+		polkaRequireSignature: envBool("POLKA_REQUIRE_SIGNATURE", false),
+		accountThrottle: auth.NewLoginThrottle(auth.ThrottleConfig{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAfter:    10,
+			LockoutDuration: time.Minute * 15,
+			ResetAfter:      time.Hour,
+		}),
+		// an IP may be shared by many users (NAT, offices), so it gets more room than a single account
+		ipThrottle: auth.NewLoginThrottle(auth.ThrottleConfig{
+			FreeAttempts:    20,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAfter:    100,
+			LockoutDuration: time.Minute * 15,
+			ResetAfter:      time.Hour,
+		}),
+		contentFilter:      contentFilter,
+		chirpCounter:       textlength.Counter{URLLength: envInt("CHIRP_URL_LENGTH", 23)},
+		chirpMaxLength:     envInt("CHIRP_MAX_LENGTH", 140),
+		chirpMaxLengthRed:  envInt("CHIRP_MAX_LENGTH_RED", 280),
+		blobs:              blobs,
+		mediaMaxBytes:      int64(envInt("MEDIA_MAX_BYTES", 5<<20)),
+		mediaMaxPixels:     envInt("MEDIA_MAX_PIXELS", 40_000_000),
+		avatarMaxBytes:     int64(envInt("AVATAR_MAX_BYTES", 2<<20)),
+		mediaBaseURL:       strings.TrimSuffix(os.Getenv("MEDIA_BASE_URL"), "/"),
+		chirpRestoreWindow: envDuration("CHIRP_RESTORE_WINDOW", time.Hour*24*7),
+		chirpRetention:     envDuration("CHIRP_RETENTION", time.Hour*24*30),
+		events:             events.NewBroker(streamBufferSize),
+		streamHeartbeat:    envDuration("STREAM_HEARTBEAT_INTERVAL", time.Second*15),
+		wsLimiter:          newWSLimiter(envInt("WS_MAX_CONNECTIONS", 10_000), envInt("WS_MAX_CONNECTIONS_PER_USER", 5)),
+		wsPingInterval:     envDuration("WS_PING_INTERVAL", time.Second*30),
+		mailer:             mailSender,
+		appBaseURL:         strings.TrimSuffix(envString("APP_BASE_URL", "http://localhost:8080"), "/"),
+		// private addresses are refused unless allowed, so a subscription can't reach the internal network
+		webhookSender:  webhooks.NewSender(webhookTimeout, envBool("WEBHOOK_ALLOW_PRIVATE", platform == "dev")),
+		webhookTimeout: webhookTimeout,
+		// animated GIFs, every frame gets decoded
+		mediaMaxFrames:          envInt("MEDIA_MAX_GIF_FRAMES", 300),
+		mediaMaxAnimationPixels: envInt("MEDIA_MAX_GIF_PIXELS", 100_000_000),
+	}
+
+	// purging before the restore window is over would make the undo fail
+	if cfg.chirpRetention < cfg.chirpRestoreWindow {
+		log.Fatalln("CHIRP_RETENTION can't be shorter than CHIRP_RESTORE_WINDOW")
+	}
+
+	// Promote the configured admins, the others are promoted by an admin through PUT /admin/users/{userID}/role
+	for _, userID := range adminUserIDs {
+		_, err := dbQueries.SetUserRole(ctx, database.SetUserRoleParams{
+			Role:      string(auth.RoleAdmin),
+			UpdatedAt: time.Now(),
+			ID:        userID,
+		})
+		if err != nil {
+			log.Printf("couldn't promote user %s to admin: %v", userID, err)
+		}
 	}
 
 	mux := http.NewServeMux()
@@ -60,6 +239,9 @@ func main() {
 		Addr:    fmt.Sprintf("%s:%d", serverIp, serverPort),
 		Handler: mux,
 	}
+	// Shutdown waits for the open streams to end, closing them lets it finish (their clients reconnect elsewhere).
+	// It doesn't track the WebSockets at all, they're closed the same way.
+	chirpyServer.RegisterOnShutdown(cfg.events.CloseAll)
 
 	// File Server related
 	mux.Handle("/app/", cfg.middlewareMetricsInc(handlerHome))
@@ -72,6 +254,34 @@ func main() {
 	mux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
 	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
 	mux.HandleFunc("POST /api/login", cfg.handlerLoginUser)
+	// avatars
+	mux.HandleFunc("PUT /api/users/me/avatar", cfg.handlerSetAvatar)
+	mux.HandleFunc("DELETE /api/users/me/avatar", cfg.handlerDeleteAvatar)
+	mux.HandleFunc("GET /api/avatars/{avatarID}/{size}", cfg.handlerGetAvatar)
+	// follows & protected accounts
+	mux.HandleFunc("PUT /api/users/me/protected", cfg.handlerSetProtected)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.handlerUnfollowUser)
+	mux.HandleFunc("GET /api/follow-requests", cfg.handlerListFollowRequests)
+	mux.HandleFunc("POST /api/follow-requests/{userID}/approve", cfg.handlerApproveFollowRequest)
+	mux.HandleFunc("POST /api/follow-requests/{userID}/deny", cfg.handlerDenyFollowRequest)
+	// blocks, mutes & muted words
+	mux.HandleFunc("POST /api/users/{userID}/block", cfg.handlerBlockUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", cfg.handlerUnblockUser)
+	mux.HandleFunc("GET /api/blocks", cfg.handlerListBlockedUsers)
+	mux.HandleFunc("POST /api/users/{userID}/mute", cfg.handlerMuteUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", cfg.handlerUnmuteUser)
+	mux.HandleFunc("GET /api/mutes", cfg.handlerListMutedUsers)
+	mux.HandleFunc("POST /api/users/me/muted-words", cfg.handlerCreateMutedWord)
+	mux.HandleFunc("GET /api/users/me/muted-words", cfg.handlerListMutedWords)
+	mux.HandleFunc("DELETE /api/users/me/muted-words/{mutedWordID}", cfg.handlerDeleteMutedWord)
+	// activity digest => the confirmation & unsubscribe links work without logging in
+	mux.HandleFunc("GET /api/users/me/digest", cfg.handlerGetDigestSettings)
+	mux.HandleFunc("PUT /api/users/me/digest", cfg.handlerSetDigestSettings)
+	mux.HandleFunc("GET /api/digest/confirm", cfg.handlerConfirmDigestPage)
+	mux.HandleFunc("POST /api/digest/confirm", cfg.handlerConfirmDigest)
+	mux.HandleFunc("GET /api/digest/unsubscribe", cfg.handlerConfirmUnsubscribeDigest)
+	mux.HandleFunc("POST /api/digest/unsubscribe", cfg.handlerUnsubscribeDigest)
 	// token
 	mux.HandleFunc("POST /api/refresh", cfg.handlerRefreshToken)
 	mux.HandleFunc("POST /api/revoke", cfg.handlerRevokeRefreshToken)
@@ -80,12 +290,207 @@ func main() {
 	mux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetSingleChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", cfg.handlerReportChirp)
+	mux.HandleFunc("GET /api/chirps/scheduled", cfg.handlerListScheduledChirps)
+	mux.HandleFunc("DELETE /api/chirps/scheduled/{chirpID}", cfg.handlerCancelScheduledChirp)
+	mux.HandleFunc("GET /api/chirps/deleted", cfg.handlerListDeletedChirps)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", cfg.handlerRestoreChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", cfg.handlerPinChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", cfg.handlerUnpinChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", cfg.handlerBookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", cfg.handlerRemoveBookmark)
+	mux.HandleFunc("GET /api/bookmarks", cfg.handlerListBookmarks)
+	// real-time => Server-Sent Events & WebSocket
+	mux.HandleFunc("GET /api/stream", cfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", cfg.handlerWebSocket)
+	// notifications
+	mux.HandleFunc("GET /api/notifications", cfg.handlerListNotifications)
+	mux.HandleFunc("POST /api/notifications/read", cfg.handlerReadAllNotifications)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", cfg.handlerReadNotification)
+	// direct messages
+	mux.HandleFunc("POST /api/conversations", cfg.handlerStartConversation)
+	mux.HandleFunc("GET /api/conversations", cfg.handlerListConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", cfg.handlerListMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", cfg.handlerSendMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", cfg.handlerReadConversation)
+	// drafts
+	mux.HandleFunc("POST /api/drafts", cfg.handlerCreateDraft)
+	mux.HandleFunc("GET /api/drafts", cfg.handlerListDrafts)
+	mux.HandleFunc("GET /api/drafts/{draftID}", cfg.handlerGetDraft)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", cfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", cfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", cfg.handlerPublishDraft)
+	// media
+	mux.HandleFunc("POST /api/media", cfg.handlerUploadMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}", cfg.handlerGetMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", cfg.handlerGetMediaThumbnail)
+	// outbound webhooks
+	mux.HandleFunc("POST /api/webhooks", cfg.handlerCreateWebhook)
+	mux.HandleFunc("GET /api/webhooks", cfg.handlerListWebhooks)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.handlerDeleteWebhook)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.handlerListWebhookDeliveries)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", cfg.handlerRedeliverWebhook)
 	// payment gateway
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerPolkaWebhooks)
 	// admin
-	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
+	mux.Handle("GET /admin/metrics", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerMetrics)))
+	// dev only, guarded by PLATFORM: it deletes every user, admins included
 	mux.HandleFunc("POST /admin/reset", cfg.handlerResetMetrics)
+	// moderation => reports queue
+	mux.Handle("GET /admin/reports", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.handlerModerationListReports)))
+	mux.Handle("GET /admin/reports/{reportID}", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.handlerModerationGetReport)))
+	mux.Handle("POST /admin/reports/{reportID}/resolve", cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(cfg.handlerModerationResolveReport)))
+	// admin => user management
+	mux.Handle("GET /admin/users", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminListUsers)))
+	mux.Handle("GET /admin/users/{userID}", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminGetUser)))
+	mux.Handle("POST /admin/users/{userID}/suspend", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminSuspendUser)))
+	mux.Handle("POST /admin/users/{userID}/unsuspend", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminUnsuspendUser)))
+	mux.Handle("POST /admin/users/{userID}/password-reset", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminForcePasswordReset)))
+	mux.Handle("PUT /admin/users/{userID}/role", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminSetUserRole)))
+	// admin => content filter rules
+	mux.Handle("GET /admin/content-filter/rules", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminListContentFilterRules)))
+	mux.Handle("POST /admin/content-filter/rules", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminCreateContentFilterRule)))
+	mux.Handle("PUT /admin/content-filter/rules/{ruleID}", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminUpdateContentFilterRule)))
+	mux.Handle("DELETE /admin/content-filter/rules/{ruleID}", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminDeleteContentFilterRule)))
+	mux.Handle("POST /admin/content-filter/reload", cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(cfg.handlerAdminReloadContentFilter)))
+
+	// Background workers => safe to run on every replica
+	go jobs.NewRunner(jobStore{db: cfg.db}, cfg.runJob).Run(ctx, envDuration("JOB_POLL_INTERVAL", time.Second))
+	go cfg.runChirpPublisher(ctx, envDuration("CHIRP_PUBLISHER_INTERVAL", time.Second*10))
+	go cfg.runChirpPurger(ctx, envDuration("CHIRP_PURGE_INTERVAL", time.Hour))
+	go cfg.runMediaPurger(ctx, envDuration("MEDIA_PURGE_INTERVAL", time.Hour), envDuration("MEDIA_UNATTACHED_TTL", time.Hour*24))
+	go cfg.runWebhookDeliverer(ctx, envDuration("WEBHOOK_DELIVERY_INTERVAL", time.Second*5), envDuration("WEBHOOK_DELIVERY_RETENTION", time.Hour*24*30))
+	go cfg.runDigestSender(ctx, envDuration("DIGEST_INTERVAL", time.Minute*10))
+	go cfg.runStreamEventPurger(ctx, envDuration("STREAM_PURGE_INTERVAL", time.Hour), envDuration("STREAM_EVENT_RETENTION", time.Hour*24))
+	go func() {
+		if err := events.Listen(ctx, dbURL, cfg.events, cfg.loadStreamEvent); err != nil {
+			log.Fatalln(err)
+		}
+	}()
+
+	go func() {
+		log.Printf("Serving files from %s on port: %d\n", serverIp, serverPort)
+		if err := chirpyServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("shutting down...")
+
+	// let the in-flight requests finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if err := chirpyServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("couldn't shut down gracefully: %v", err)
+	}
+}
+
+// newPasswordManager builds the password manager for the chosen algorithm, tuned by env variables.
+// Both algorithms are always accepted for verification, only the preferred one is used for new hashes.
+func newPasswordManager(algorithm string) *auth.PasswordManager {
+	bcryptHasher := auth.BcryptHasher{Cost: envInt("BCRYPT_COST", 10)}
+
+	argon2idHasher := auth.DefaultArgon2idHasher()
+	argon2idHasher.Memory = uint32(envInt("ARGON2_MEMORY_KIB", int(argon2idHasher.Memory)))
+	argon2idHasher.Iterations = uint32(envInt("ARGON2_ITERATIONS", int(argon2idHasher.Iterations)))
+	argon2idHasher.Parallelism = uint8(envInt("ARGON2_PARALLELISM", int(argon2idHasher.Parallelism)))
+
+	var manager *auth.PasswordManager
+	switch algorithm {
+	case "bcrypt":
+		manager = auth.NewPasswordManager(bcryptHasher, argon2idHasher)
+	default:
+		if algorithm != "argon2id" {
+			log.Printf("unknown PASSWORD_HASHER=%q, using argon2id", algorithm)
+		}
+		manager = auth.NewPasswordManager(argon2idHasher, bcryptHasher)
+	}
+
+	// every argon2id hash holds its memory, a burst of logins gets 503s instead of running the process out of it.
+	// By default the hashes can take a quarter of the memory
+	concurrency := envInt("PASSWORD_HASH_CONCURRENCY", int(memoryLimit()/4/max(int64(argon2idHasher.Memory)<<10, 1)))
+	manager.LimitConcurrency(concurrency, envDuration("PASSWORD_HASH_WAIT", time.Second*2))
+
+	return manager
+}
+
+// memoryLimit returns the memory the process can use => GOMEMLIMIT, else the limit of its cgroup (container),
+// else the memory of the machine
+func memoryLimit() int64 {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+
+	// "max" when the cgroup has no limit
+	if data, err := os.ReadFile("/sys/fs/cgroup/memory.max"); err == nil {
+		if limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			return limit
+		}
+	}
+
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		for line := range strings.Lines(string(data)) {
+			var totalKiB int64
+			if _, err := fmt.Sscanf(line, "MemTotal: %d kB", &totalKiB); err == nil {
+				return totalKiB << 10
+			}
+		}
+	}
+
+	return 1 << 30
+}
+
+// newBlobStore builds the storage backend for the uploaded media, configured by env variables
+func newBlobStore(backend string) (storage.BlobStore, error) {
+	switch backend {
+	case "local":
+		return storage.LocalStore{Root: envString("STORAGE_LOCAL_PATH", "./uploads")}, nil
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:        envString("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          envString("S3_REGION", "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       envBool("S3_PATH_STYLE", false),
+		})
+	}
+
+	return nil, fmt.Errorf("unknown STORAGE_BACKEND=%q, use local or s3", backend)
+}
+
+// newMailer builds the email sender, configured by env variables
+func newMailer(backend string) (mailer.Mailer, error) {
+	switch backend {
+	case "log":
+		return mailer.LogMailer{}, nil
+	case "smtp":
+		return mailer.SMTPMailer{
+			Addr:     envString("SMTP_ADDR", "localhost:1025"),
+			From:     envString("SMTP_FROM", "Chirpy <noreply@chirpy.local>"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown MAILER=%q, use log or smtp", backend)
+}
+
+// parseUUIDList splits a comma separated list of UUIDs, dropping empty entries
+func parseUUIDList(list string) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, err := uuid.Parse(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a user ID", entry)
+		}
+		ids = append(ids, id)
+	}
 
-	log.Printf("Serving files from %s on port: %d\n", serverIp, serverPort)
-	log.Fatal(chirpyServer.ListenAndServe())
+	return ids, nil
 }
@@ -1,6 +1,18 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/MeYo0o/chirpy_server/internal/auth"
+	"github.com/MeYo0o/chirpy_server/internal/database"
+)
+
+type contextKey string
+
+// userContextKey holds the authenticated database.User, set by middlewareRequireRole
+const userContextKey contextKey = "user"
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -8,3 +20,30 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// middlewareRequireRole only lets through authenticated users whose role satisfies the required one,
+// the user is then available to the handler through userFromContext
+func (cfg *apiConfig) middlewareRequireRole(role auth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// the role is read from the DB on every request, so role changes apply immediately
+		user, err := cfg.authenticateUser(r)
+		if err != nil {
+			writeErrorResponse(rw, 401, err.Error())
+			return
+		}
+
+		if !auth.Role(user.Role).Satisfies(role) {
+			writeErrorResponse(rw, 403, fmt.Sprintf("forbidden: requires the %s role", role))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+// userFromContext returns the user stored by middlewareRequireRole
+func userFromContext(ctx context.Context) (database.User, bool) {
+	user, ok := ctx.Value(userContextKey).(database.User)
+	return user, ok
+}
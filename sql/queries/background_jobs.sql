@@ -0,0 +1,28 @@
+-- name: CreateBackgroundJob :exec
+INSERT INTO background_jobs(id, kind, payload, run_at, created_at)
+VALUES($1, $2, $3, $4, $4);
+-- name: ClaimDueBackgroundJobs :many
+-- pushes a batch of due jobs back by the lease, so another replica doesn't run them while they're in flight,
+-- and a crashed worker's jobs run again once it's over. FOR UPDATE SKIP LOCKED lets replicas split the work.
+UPDATE background_jobs
+SET run_at = sqlc.arg(lease_until)::timestamp,
+  attempts = attempts + 1
+WHERE id IN (
+    SELECT id
+    FROM background_jobs
+    WHERE run_at <= sqlc.arg(now)::timestamp
+    ORDER BY run_at ASC
+    LIMIT sqlc.arg(batch_size)::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING *;
+-- name: DeleteBackgroundJob :execrows
+-- only while the job is still claimed by this attempt, not once it was claimed again after its lease
+DELETE FROM background_jobs
+WHERE id = sqlc.arg(id)
+  AND attempts = sqlc.arg(attempts);
+-- name: RetryBackgroundJob :execrows
+UPDATE background_jobs
+SET run_at = sqlc.arg(run_at),
+  last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id)
+  AND attempts = sqlc.arg(attempts);
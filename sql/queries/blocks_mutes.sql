@@ -0,0 +1,31 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks(blocker_id, blocked_id, created_at)
+VALUES($1, $2, $3) ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1
+  AND blocked_id = $2;
+-- name: ListBlockedUsers :many
+SELECT *
+FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+-- name: IsBlocked :one
+SELECT EXISTS(
+    SELECT 1
+    FROM user_blocks
+    WHERE blocker_id = $1
+      AND blocked_id = $2
+  );
+-- name: MuteUser :exec
+INSERT INTO user_mutes(muter_id, muted_id, created_at)
+VALUES($1, $2, $3) ON CONFLICT (muter_id, muted_id) DO NOTHING;
+-- name: UnmuteUser :execrows
+DELETE FROM user_mutes
+WHERE muter_id = $1
+  AND muted_id = $2;
+-- name: ListMutedUsers :many
+SELECT *
+FROM user_mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
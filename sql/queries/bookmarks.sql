@@ -0,0 +1,63 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks(user_id, chirp_id, created_at)
+VALUES($1, $2, $3) ON CONFLICT (user_id, chirp_id) DO NOTHING;
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+  AND chirp_id = $2;
+-- name: ListBookmarkedChirps :many
+SELECT chirps.*
+FROM bookmarks
+  JOIN chirps ON chirps.id = bookmarks.chirp_id
+  JOIN users ON users.id = chirps.user_id
+WHERE bookmarks.user_id = $1
+  AND users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = bookmarks.user_id
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = bookmarks.user_id
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = bookmarks.user_id
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  )
+ORDER BY bookmarks.created_at DESC
+LIMIT $2 OFFSET $3;
+-- name: CountBookmarkedChirps :one
+SELECT COUNT(*)
+FROM bookmarks
+  JOIN chirps ON chirps.id = bookmarks.chirp_id
+  JOIN users ON users.id = chirps.user_id
+WHERE bookmarks.user_id = $1
+  AND users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = bookmarks.user_id
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = bookmarks.user_id
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = bookmarks.user_id
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  );
@@ -0,0 +1,48 @@
+-- name: CreateChirpReport :one
+INSERT INTO chirp_reports(
+    id,
+    chirp_id,
+    chirp_author_id,
+    chirp_body,
+    reporter_id,
+    reason,
+    details,
+    created_at,
+    updated_at
+  )
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+-- name: GetChirpReport :one
+SELECT *
+FROM chirp_reports
+WHERE id = $1;
+-- name: GetChirpReportForUpdate :one
+SELECT *
+FROM chirp_reports
+WHERE id = $1 FOR UPDATE;
+-- name: ListChirpReports :many
+SELECT *
+FROM chirp_reports
+WHERE status = $1
+ORDER BY created_at ASC
+LIMIT $2 OFFSET $3;
+-- name: CountChirpReports :one
+SELECT COUNT(*)
+FROM chirp_reports
+WHERE status = $1;
+-- name: ResolveChirpReport :one
+UPDATE chirp_reports
+SET status = 'resolved',
+  resolved_at = $1,
+  updated_at = $2
+WHERE id = $3
+RETURNING *;
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions(id, report_id, moderator_id, action, note, created_at)
+VALUES($1, $2, $3, $4, $5, $6)
+RETURNING *;
+-- name: GetModerationActionsByReportID :many
+SELECT *
+FROM moderation_actions
+WHERE report_id = $1
+ORDER BY created_at ASC;
@@ -1,26 +1,207 @@
 -- name: CreateChirpy :one
-INSERT INTO chirps(id, body, user_id, created_at, updated_at)
-VALUES($1, $2, $3, $4, $5)
+INSERT INTO chirps(
+    id,
+    body,
+    user_id,
+    created_at,
+    updated_at,
+    publish_at,
+    published_at
+  )
+VALUES($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 -- name: GetChirps :many
-SELECT *
+-- the viewer (null when anonymous) doesn't see chirps across a block in either direction, nor the ones of users they muted,
+-- and only sees protected accounts they follow
+SELECT chirps.*
 FROM chirps
-ORDER BY created_at ASC;
+  JOIN users ON users.id = chirps.user_id
+WHERE users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE (
+        user_blocks.blocker_id = chirps.user_id
+        AND user_blocks.blocked_id = sqlc.narg('viewer_id')::uuid
+      )
+      OR (
+        user_blocks.blocker_id = sqlc.narg('viewer_id')::uuid
+        AND user_blocks.blocked_id = chirps.user_id
+      )
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.narg('viewer_id')::uuid
+      AND user_mutes.muted_id = chirps.user_id
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = sqlc.narg('viewer_id')::uuid
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = sqlc.narg('viewer_id')::uuid
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  )
+ORDER BY chirps.published_at ASC;
 -- name: GetChirpy :one
 SELECT *
 From chirps
-WHERE id = $1;
+WHERE id = $1
+  AND deleted_at IS NULL;
 -- name: GetChirpsByUserID :many
-SELECT *
+-- a profile is empty for the users its author blocked, and for non-followers when it's protected, muting doesn't hide it
+SELECT chirps.*
 FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC;
+  JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id)::uuid
+  AND users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = sqlc.narg('viewer_id')::uuid
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = sqlc.narg('viewer_id')::uuid
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = sqlc.narg('viewer_id')::uuid
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  )
+ORDER BY chirps.published_at ASC;
+-- name: GetVisibleChirp :one
+-- a published chirp as the viewer (null when anonymous) may see it, following blocks & protected accounts
+SELECT chirps.*
+FROM chirps
+  JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = sqlc.arg(id)::uuid
+  AND users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = chirps.user_id
+      AND user_blocks.blocked_id = sqlc.narg('viewer_id')::uuid
+  )
+  AND (
+    users.is_protected = FALSE
+    OR chirps.user_id = sqlc.narg('viewer_id')::uuid
+    OR EXISTS (
+      SELECT 1
+      FROM follows
+      WHERE follows.follower_id = sqlc.narg('viewer_id')::uuid
+        AND follows.followee_id = chirps.user_id
+        AND follows.status = 'accepted'
+    )
+  );
 -- name: GetChirpyByUserID :one
 SELECT *
 FROM chirps
 WHERE id = $1
-  AND user_id = $2;
--- name: DeleteChirp :exec
+  AND user_id = $2
+  AND deleted_at IS NULL;
+-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = $1,
+  deleted_by = $2,
+  updated_at = $3
+WHERE id = $4
+  AND user_id = $5
+  AND deleted_at IS NULL;
+-- name: RestoreChirp :execrows
+UPDATE chirps
+SET deleted_at = NULL,
+  deleted_by = NULL,
+  updated_at = sqlc.arg(updated_at)::timestamp
+WHERE id = sqlc.arg(id)::uuid
+  AND user_id = sqlc.arg(user_id)::uuid
+  AND deleted_by = sqlc.arg(user_id)::uuid
+  AND deleted_at >= sqlc.arg(deleted_after)::timestamp;
+-- name: ListDeletedChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id)::uuid
+  AND deleted_by = sqlc.arg(user_id)::uuid
+  AND deleted_at >= sqlc.arg(deleted_after)::timestamp
+ORDER BY deleted_at DESC;
+-- name: GetPurgeableChirpIDs :many
+SELECT id
+FROM chirps
+WHERE deleted_at < sqlc.arg(deleted_before)::timestamp
+ORDER BY deleted_at ASC
+LIMIT sqlc.arg(batch_size)::int FOR UPDATE SKIP LOCKED;
+-- name: DeleteChirpsByIDs :exec
+DELETE FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = $1,
+  updated_at = $2
+WHERE id = $3;
+-- name: ListScheduledChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1
+  AND published_at IS NULL
+  AND deleted_at IS NULL
+ORDER BY publish_at ASC;
+-- name: CancelScheduledChirp :execrows
 DELETE FROM chirps
 WHERE id = $1
-  AND user_id = $2;
\ No newline at end of file
+  AND user_id = $2
+  AND published_at IS NULL;
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET published_at = publish_at,
+  updated_at = sqlc.arg(now)::timestamp
+WHERE id IN (
+    SELECT id
+    FROM chirps
+    WHERE published_at IS NULL
+      AND deleted_at IS NULL
+      AND publish_at <= sqlc.arg(now)::timestamp
+    ORDER BY publish_at ASC
+    LIMIT sqlc.arg(batch_size)::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING *;
+-- name: ListTopFollowedChirpsSince :many
+-- the most bookmarked chirps the user's followed accounts published since the given time, for the digest
+SELECT chirps.*
+FROM chirps
+  JOIN users ON users.id = chirps.user_id
+  JOIN follows ON follows.followee_id = chirps.user_id
+  AND follows.follower_id = sqlc.arg(user_id)::uuid
+  AND follows.status = 'accepted'
+WHERE users.chirps_hidden = FALSE
+  AND chirps.hidden_at IS NULL
+  AND chirps.deleted_at IS NULL
+  AND chirps.published_at >= sqlc.arg(since)::timestamp
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.arg(user_id)::uuid
+      AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY (
+    SELECT COUNT(*)
+    FROM bookmarks
+    WHERE bookmarks.chirp_id = chirps.id
+  ) DESC,
+  chirps.published_at DESC
+LIMIT sqlc.arg('limit');
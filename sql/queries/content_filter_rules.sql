@@ -0,0 +1,22 @@
+-- name: ListContentFilterRules :many
+SELECT *
+FROM content_filter_rules
+ORDER BY created_at ASC;
+-- name: GetContentFilterRule :one
+SELECT *
+FROM content_filter_rules
+WHERE id = $1;
+-- name: CreateContentFilterRule :one
+INSERT INTO content_filter_rules(id, pattern, action, created_at, updated_at)
+VALUES($1, $2, $3, $4, $5)
+RETURNING *;
+-- name: UpdateContentFilterRule :one
+UPDATE content_filter_rules
+SET pattern = $1,
+  action = $2,
+  updated_at = $3
+WHERE id = $4
+RETURNING *;
+-- name: DeleteContentFilterRule :execrows
+DELETE FROM content_filter_rules
+WHERE id = $1;
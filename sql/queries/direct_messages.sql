@@ -0,0 +1,59 @@
+-- name: CreateConversation :one
+-- an existing conversation between the pair is returned as is
+INSERT INTO conversations(id, user_a, user_b, created_at, updated_at)
+VALUES($1, $2, $3, $4, $4) ON CONFLICT (user_a, user_b) DO
+UPDATE
+SET updated_at = conversations.updated_at
+RETURNING *;
+-- name: GetConversationForUser :one
+SELECT *
+FROM conversations
+WHERE id = sqlc.arg(id)::uuid
+  AND (
+    user_a = sqlc.arg(user_id)::uuid
+    OR user_b = sqlc.arg(user_id)::uuid
+  );
+-- name: ListConversations :many
+SELECT conversations.*,
+  (
+    SELECT COUNT(*)
+    FROM messages
+    WHERE messages.conversation_id = conversations.id
+      AND messages.sender_id <> sqlc.arg(user_id)::uuid
+      AND messages.read_at IS NULL
+  )::bigint AS unread_count
+FROM conversations
+WHERE conversations.user_a = sqlc.arg(user_id)::uuid
+  OR conversations.user_b = sqlc.arg(user_id)::uuid
+ORDER BY conversations.updated_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = $1
+WHERE id = $2;
+-- name: CreateMessage :one
+INSERT INTO messages(id, conversation_id, sender_id, body, created_at)
+VALUES($1, $2, $3, $4, $5)
+RETURNING *;
+-- name: ListMessages :many
+SELECT *
+FROM messages
+WHERE conversation_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+-- name: CountMessages :one
+SELECT COUNT(*)
+FROM messages
+WHERE conversation_id = $1;
+-- name: MarkConversationRead :execrows
+-- marks the messages the reader received, their own messages are read by the other participant
+UPDATE messages
+SET read_at = $1
+WHERE conversation_id = $2
+  AND sender_id <> $3
+  AND read_at IS NULL;
+-- name: CountConversations :one
+SELECT COUNT(*)
+FROM conversations
+WHERE user_a = sqlc.arg(user_id)::uuid
+  OR user_b = sqlc.arg(user_id)::uuid;
@@ -0,0 +1,26 @@
+-- name: CreateDraft :one
+INSERT INTO drafts(id, user_id, body, media_ids, created_at, updated_at)
+VALUES($1, $2, $3, $4, $5, $6)
+RETURNING *;
+-- name: GetDraft :one
+SELECT *
+FROM drafts
+WHERE id = $1
+  AND user_id = $2;
+-- name: ListDrafts :many
+SELECT *
+FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $1,
+  media_ids = $2,
+  updated_at = $3
+WHERE id = $4
+  AND user_id = $5
+RETURNING *;
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1
+  AND user_id = $2;
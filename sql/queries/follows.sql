@@ -0,0 +1,62 @@
+-- name: FollowUser :one
+-- following again keeps the current follow, whatever its status
+INSERT INTO follows(
+    follower_id,
+    followee_id,
+    status,
+    created_at,
+    updated_at
+  )
+VALUES($1, $2, $3, $4, $4) ON CONFLICT (follower_id, followee_id) DO
+UPDATE
+SET updated_at = follows.updated_at
+RETURNING *;
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2;
+-- name: ListFollowRequests :many
+SELECT *
+FROM follows
+WHERE followee_id = $1
+  AND status = 'pending'
+ORDER BY created_at ASC;
+-- name: ApproveFollowRequest :execrows
+UPDATE follows
+SET status = 'accepted',
+  updated_at = $1
+WHERE follower_id = $2
+  AND followee_id = $3
+  AND status = 'pending';
+-- name: DenyFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2
+  AND status = 'pending';
+-- name: ApproveAllFollowRequests :exec
+UPDATE follows
+SET status = 'accepted',
+  updated_at = $1
+WHERE followee_id = $2
+  AND status = 'pending';
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (
+    follower_id = sqlc.arg(user_a)::uuid
+    AND followee_id = sqlc.arg(user_b)::uuid
+  )
+  OR (
+    follower_id = sqlc.arg(user_b)::uuid
+    AND followee_id = sqlc.arg(user_a)::uuid
+  );
+-- name: GetFollow :one
+SELECT *
+FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2;
+-- name: CountNewFollowersSince :one
+SELECT COUNT(*)
+FROM follows
+WHERE followee_id = sqlc.arg(followee_id)
+  AND status = 'accepted'
+  AND updated_at >= sqlc.arg(since)::timestamp;
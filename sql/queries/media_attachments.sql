@@ -0,0 +1,92 @@
+-- name: CreateMediaAttachment :one
+INSERT INTO media_attachments(
+    id,
+    user_id,
+    content_type,
+    width,
+    height,
+    size_bytes,
+    storage_key,
+    thumbnail_content_type,
+    thumbnail_key,
+    created_at
+  )
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+-- name: AttachMediaToChirp :execrows
+UPDATE media_attachments
+SET chirp_id = $1,
+  position = $2
+WHERE id = $3
+  AND user_id = $4
+  AND chirp_id IS NULL;
+-- name: GetMediaAttachmentsByChirpIDs :many
+SELECT *
+FROM media_attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id,
+  position ASC;
+-- name: GetVisibleMediaAttachment :one
+-- media the viewer (null when anonymous) may see: their own uploads, or the media of a chirp GetVisibleChirp would return.
+-- public tells whether anyone may see it, only then can it be cached by shared caches
+SELECT media_attachments.*,
+  COALESCE(
+    chirps.published_at IS NOT NULL
+    AND chirps.hidden_at IS NULL
+    AND chirps.deleted_at IS NULL
+    AND users.chirps_hidden = FALSE
+    AND users.is_protected = FALSE,
+    FALSE
+  )::bool AS public
+FROM media_attachments
+  LEFT JOIN chirps ON chirps.id = media_attachments.chirp_id
+  LEFT JOIN users ON users.id = chirps.user_id
+WHERE media_attachments.id = sqlc.arg(id)::uuid
+  AND (
+    media_attachments.user_id = sqlc.narg('viewer_id')::uuid
+    OR (
+      chirps.published_at IS NOT NULL
+      AND chirps.hidden_at IS NULL
+      AND chirps.deleted_at IS NULL
+      AND users.chirps_hidden = FALSE
+      AND NOT EXISTS (
+        SELECT 1
+        FROM user_blocks
+        WHERE user_blocks.blocker_id = chirps.user_id
+          AND user_blocks.blocked_id = sqlc.narg('viewer_id')::uuid
+      )
+      AND (
+        users.is_protected = FALSE
+        OR chirps.user_id = sqlc.narg('viewer_id')::uuid
+        OR EXISTS (
+          SELECT 1
+          FROM follows
+          WHERE follows.follower_id = sqlc.narg('viewer_id')::uuid
+            AND follows.followee_id = chirps.user_id
+            AND follows.status = 'accepted'
+        )
+      )
+    )
+  );
+-- name: DeleteUnattachedMedia :many
+-- uploads never attached to a chirp, the ones a draft still refers to are kept
+DELETE FROM media_attachments
+WHERE id IN (
+    SELECT media_attachments.id
+    FROM media_attachments
+    WHERE media_attachments.chirp_id IS NULL
+      AND media_attachments.created_at < sqlc.arg(created_before)::timestamp
+      AND NOT EXISTS (
+        SELECT 1
+        FROM drafts
+        WHERE media_attachments.id = ANY(drafts.media_ids)
+      )
+    ORDER BY media_attachments.created_at ASC
+    LIMIT sqlc.arg(batch_size)::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING *;
+-- name: DeleteMediaAttachmentsByIDs :many
+DELETE FROM media_attachments
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+  AND chirp_id IS NULL
+RETURNING *;
@@ -0,0 +1,32 @@
+-- name: CreateMutedWord :one
+-- an expired duplicate is replaced, it's no longer in use
+INSERT INTO muted_words(id, user_id, phrase, expires_at, created_at)
+VALUES($1, $2, $3, $4, $5) ON CONFLICT (user_id, phrase) DO
+UPDATE
+SET id = EXCLUDED.id,
+  expires_at = EXCLUDED.expires_at,
+  created_at = EXCLUDED.created_at
+WHERE muted_words.expires_at IS NOT NULL
+  AND muted_words.expires_at <= EXCLUDED.created_at
+RETURNING *;
+-- name: ListActiveMutedWords :many
+SELECT *
+FROM muted_words
+WHERE user_id = sqlc.arg(user_id)::uuid
+  AND (
+    expires_at IS NULL
+    OR expires_at > sqlc.arg(now)::timestamp
+  )
+ORDER BY created_at DESC;
+-- name: CountActiveMutedWords :one
+SELECT COUNT(*)
+FROM muted_words
+WHERE user_id = sqlc.arg(user_id)::uuid
+  AND (
+    expires_at IS NULL
+    OR expires_at > sqlc.arg(now)::timestamp
+  );
+-- name: DeleteMutedWord :execrows
+DELETE FROM muted_words
+WHERE id = $1
+  AND user_id = $2;
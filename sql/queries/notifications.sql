@@ -0,0 +1,54 @@
+-- name: CreateNotification :one
+-- nothing is created when the user blocked or muted the actor
+INSERT INTO notifications(id, user_id, type, actor_id, created_at)
+SELECT sqlc.arg(id)::uuid,
+  sqlc.arg(user_id)::uuid,
+  sqlc.arg(type)::text,
+  sqlc.narg(actor_id)::uuid,
+  sqlc.arg(created_at)::timestamp
+WHERE sqlc.narg(actor_id)::uuid IS NULL
+  OR NOT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE user_blocks.blocker_id = sqlc.arg(user_id)::uuid
+      AND user_blocks.blocked_id = sqlc.narg(actor_id)::uuid
+    UNION ALL
+    SELECT 1
+    FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.arg(user_id)::uuid
+      AND user_mutes.muted_id = sqlc.narg(actor_id)::uuid
+  )
+RETURNING *;
+-- name: ListNotifications :many
+SELECT *
+FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  AND (
+    NOT sqlc.arg(unread_only)::bool
+    OR read_at IS NULL
+  )
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+-- name: CountNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+  AND (
+    NOT sqlc.arg(unread_only)::bool
+    OR read_at IS NULL
+  );
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1
+  AND read_at IS NULL;
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, sqlc.arg(read_at)::timestamp)
+WHERE id = sqlc.arg(id)
+  AND user_id = sqlc.arg(user_id);
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = $1
+WHERE user_id = $2
+  AND read_at IS NULL;
@@ -0,0 +1,41 @@
+-- name: CreateFollowerEvents :exec
+-- one event per accepted follower of the author, except the ones who muted them
+INSERT INTO stream_events(user_id, type, payload, created_at)
+SELECT follows.follower_id,
+  sqlc.arg(type)::text,
+  sqlc.arg(payload)::jsonb,
+  sqlc.arg(created_at)::timestamp
+FROM follows
+WHERE follows.followee_id = sqlc.arg(author_id)::uuid
+  AND follows.status = 'accepted'
+  AND NOT EXISTS (
+    SELECT 1
+    FROM user_mutes
+    WHERE user_mutes.muter_id = follows.follower_id
+      AND user_mutes.muted_id = follows.followee_id
+  );
+-- name: GetStreamEvent :one
+SELECT *
+FROM stream_events
+WHERE id = $1;
+-- name: ListStreamEventsForReplay :many
+-- the events after the cursor, plus the ones stored since overlap_since: an ID below the cursor can be
+-- committed after it, the client skips the events it already got by ID
+SELECT *
+FROM stream_events
+WHERE user_id = sqlc.arg(user_id)
+  AND (
+    id > sqlc.arg(after_id)
+    OR created_at >= sqlc.arg(overlap_since)::timestamp
+  )
+ORDER BY id ASC
+LIMIT sqlc.arg('limit');
+-- name: DeleteStreamEventsBefore :execrows
+DELETE FROM stream_events
+WHERE created_at < $1;
+-- name: CreateStreamEvent :exec
+INSERT INTO stream_events(user_id, type, payload, created_at)
+VALUES($1, $2, $3, $4);
+-- name: NotifyEphemeralEvent :exec
+-- events that aren't worth storing (typing indicators) go straight to the replicas' listeners
+SELECT pg_notify('stream_ephemeral', sqlc.arg(payload)::text);
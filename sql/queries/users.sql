@@ -4,9 +4,10 @@ INSERT INTO users (
     created_at,
     updated_at,
     email,
-    hashed_password
+    hashed_password,
+    role
   )
-VALUES ($1, $2, $3, $4, $5)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 -- name: GetUserByEmail :one
 SELECT *
@@ -17,14 +18,210 @@ SELECT *
 FROM users
 WHERE id = $1;
 -- name: UpdateUser :one
+-- a pending digest confirmation was sent to the previous address, it can't confirm the new one
 UPDATE users
 SET email = $1,
-  hashed_password = $2
+  hashed_password = $2,
+  password_reset_required = FALSE,
+  digest_pending_frequency = CASE
+    WHEN email = $1 THEN digest_pending_frequency
+  END,
+  digest_confirmation_token = CASE
+    WHEN email = $1 THEN digest_confirmation_token
+  END
 WHERE id = $3
 RETURNING *;
--- name: UpgradeUserToRed :exec
+-- name: UpgradeUserToRed :execrows
+-- no row when the user already has it, Polka retries its webhooks
 UPDATE users
 SET is_chirpy_red = TRUE
-WHERE id = $1;
+WHERE id = $1
+  AND is_chirpy_red = FALSE;
 -- name: DeleteUsers :exec
-DELETE FROM users;
\ No newline at end of file
+DELETE FROM users;
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE id = $2;
+-- name: SetUserRole :one
+UPDATE users
+SET role = $1,
+  updated_at = $2
+WHERE id = $3
+RETURNING *;
+-- name: ListUsers :many
+SELECT *
+FROM users
+WHERE (
+    sqlc.narg('search')::text IS NULL
+    OR email ILIKE '%' || sqlc.narg('search')::text || '%' ESCAPE '\'
+  )
+  AND (
+    sqlc.narg('role')::text IS NULL
+    OR role = sqlc.narg('role')::text
+  )
+  AND (
+    sqlc.narg('suspended')::bool IS NULL
+    OR (suspended_at IS NOT NULL) = sqlc.narg('suspended')::bool
+  )
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+WHERE (
+    sqlc.narg('search')::text IS NULL
+    OR email ILIKE '%' || sqlc.narg('search')::text || '%' ESCAPE '\'
+  )
+  AND (
+    sqlc.narg('role')::text IS NULL
+    OR role = sqlc.narg('role')::text
+  )
+  AND (
+    sqlc.narg('suspended')::bool IS NULL
+    OR (suspended_at IS NOT NULL) = sqlc.narg('suspended')::bool
+  );
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = $1,
+  suspension_reason = $2,
+  chirps_hidden = $3,
+  updated_at = $4
+WHERE id = $5
+RETURNING *;
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL,
+  suspension_reason = '',
+  chirps_hidden = FALSE,
+  updated_at = $1
+WHERE id = $2
+RETURNING *;
+-- name: RequirePasswordReset :one
+UPDATE users
+SET password_reset_required = TRUE,
+  updated_at = $1
+WHERE id = $2
+RETURNING *;
+-- name: SetUserAvatar :one
+UPDATE users
+SET avatar_id = $1,
+  avatar_content_type = $2,
+  avatar_updated_at = $3,
+  updated_at = $4
+WHERE id = $5
+RETURNING *;
+-- name: GetUserByAvatarID :one
+SELECT *
+FROM users
+WHERE avatar_id = $1;
+-- name: PinChirp :exec
+UPDATE users
+SET pinned_chirp_id = $1,
+  updated_at = $2
+WHERE id = $3;
+-- name: UnpinChirp :execrows
+UPDATE users
+SET pinned_chirp_id = NULL,
+  updated_at = $1
+WHERE id = $2
+  AND pinned_chirp_id = $3;
+-- name: SetUserProtected :one
+UPDATE users
+SET is_protected = $1,
+  updated_at = $2
+WHERE id = $3
+RETURNING *;
+-- name: SetDigestFrequency :one
+-- the unsubscribe token is created the first time, and kept afterwards so the links already sent keep working
+UPDATE users
+SET digest_frequency = sqlc.arg(digest_frequency),
+  digest_unsubscribe_token = COALESCE(
+    digest_unsubscribe_token,
+    sqlc.arg(unsubscribe_token)::text
+  ),
+  digest_pending_frequency = NULL,
+  digest_confirmation_token = NULL,
+  updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+-- name: RequestDigestConfirmation :one
+-- keeps the frequency asked for until the address is confirmed with the token, no row during the resend cooldown
+UPDATE users
+SET digest_pending_frequency = sqlc.arg(pending_frequency)::text,
+  digest_confirmation_token = sqlc.arg(confirmation_token)::text,
+  digest_confirmation_sent_at = sqlc.arg(updated_at)::timestamp,
+  digest_unsubscribe_token = COALESCE(
+    digest_unsubscribe_token,
+    sqlc.arg(unsubscribe_token)::text
+  ),
+  updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id)
+  AND (
+    digest_confirmation_sent_at IS NULL
+    OR digest_confirmation_sent_at <= sqlc.arg(resend_before)::timestamp
+  )
+RETURNING *;
+-- name: GetUserByDigestConfirmationToken :one
+SELECT *
+FROM users
+WHERE digest_confirmation_token = sqlc.arg(token)::text;
+-- name: ConfirmDigest :one
+UPDATE users
+SET digest_frequency = digest_pending_frequency,
+  digest_confirmed_email = email,
+  digest_pending_frequency = NULL,
+  digest_confirmation_token = NULL,
+  updated_at = sqlc.arg(updated_at)
+WHERE digest_confirmation_token = sqlc.arg(token)::text
+  AND digest_pending_frequency IS NOT NULL
+RETURNING *;
+-- name: GetUserByDigestUnsubscribeToken :one
+SELECT *
+FROM users
+WHERE digest_unsubscribe_token = sqlc.arg(token)::text;
+-- name: UnsubscribeFromDigest :execrows
+UPDATE users
+SET digest_frequency = 'off',
+  updated_at = sqlc.arg(updated_at)
+WHERE digest_unsubscribe_token = sqlc.arg(token)::text;
+-- name: ClaimDueDigests :many
+-- leases a batch of due digests, FOR UPDATE SKIP LOCKED lets replicas split the work.
+-- A digest that wasn't marked sent when its lease is over (failed delivery, crash) is claimed again
+UPDATE users
+SET digest_claimed_until = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id
+    FROM users
+    WHERE suspended_at IS NULL
+      AND (
+        digest_claimed_until IS NULL
+        OR digest_claimed_until <= sqlc.arg(now)::timestamp
+      )
+      -- the address changed since it was confirmed
+      AND digest_confirmed_email = email
+      AND (
+        (
+          digest_frequency = 'daily'
+          AND (
+            digest_sent_at IS NULL
+            OR digest_sent_at <= sqlc.arg(daily_before)::timestamp
+          )
+        )
+        OR (
+          digest_frequency = 'weekly'
+          AND (
+            digest_sent_at IS NULL
+            OR digest_sent_at <= sqlc.arg(weekly_before)::timestamp
+          )
+        )
+      )
+    ORDER BY digest_sent_at ASC NULLS FIRST
+    LIMIT sqlc.arg(batch_size)::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING *;
+-- name: MarkDigestSent :exec
+UPDATE users
+SET digest_sent_at = sqlc.arg(sent_at)::timestamp,
+  digest_claimed_until = NULL
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,115 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions(
+    id,
+    user_id,
+    url,
+    secret,
+    events,
+    created_at,
+    updated_at
+  )
+VALUES($1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+-- name: ListWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+WHERE user_id = $1
+ORDER BY created_at DESC;
+-- name: ListGlobalWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+WHERE user_id IS NULL
+ORDER BY created_at DESC;
+-- name: GetWebhookSubscription :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = $1;
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1;
+-- name: CreateWebhookDeliveries :exec
+-- one delivery per subscription to the event, the owner's ones and the global ones.
+-- The global ones only get the chirps visible to everyone, not the ones of protected or hidden accounts
+INSERT INTO webhook_deliveries(
+    id,
+    subscription_id,
+    event,
+    payload,
+    status,
+    next_attempt_at,
+    created_at,
+    updated_at
+  )
+SELECT gen_random_uuid(),
+  webhook_subscriptions.id,
+  sqlc.arg(event)::text,
+  sqlc.arg(payload)::jsonb,
+  'pending',
+  sqlc.arg(created_at)::timestamp,
+  sqlc.arg(created_at)::timestamp,
+  sqlc.arg(created_at)::timestamp
+FROM webhook_subscriptions
+  JOIN users ON users.id = sqlc.arg(user_id)::uuid
+WHERE sqlc.arg(event)::text = ANY(webhook_subscriptions.events)
+  AND (
+    (
+      webhook_subscriptions.user_id IS NULL
+      AND (
+        sqlc.arg(event)::text NOT LIKE 'chirp.%'
+        OR (
+          users.is_protected = FALSE
+          AND users.chirps_hidden = FALSE
+        )
+      )
+    )
+    OR webhook_subscriptions.user_id = sqlc.arg(user_id)::uuid
+  );
+-- name: ClaimDueWebhookDeliveries :many
+-- pushes the next attempt of a batch of due deliveries back by the lease, so another replica (or a crash) doesn't
+-- send them twice while they're in flight. FOR UPDATE SKIP LOCKED lets replicas split the work.
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending'
+      AND next_attempt_at <= sqlc.arg(now)::timestamp
+    ORDER BY next_attempt_at ASC
+    LIMIT sqlc.arg(batch_size)::int FOR UPDATE SKIP LOCKED
+  )
+RETURNING *;
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status),
+  attempts = attempts + 1,
+  next_attempt_at = sqlc.arg(next_attempt_at),
+  last_status_code = sqlc.narg(last_status_code),
+  last_response = sqlc.arg(last_response),
+  last_error = sqlc.arg(last_error),
+  delivered_at = sqlc.narg(delivered_at),
+  updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id);
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg(subscription_id)
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+-- name: CountWebhookDeliveries :one
+SELECT COUNT(*)
+FROM webhook_deliveries
+WHERE subscription_id = $1;
+-- name: RedeliverWebhook :one
+-- a manual redelivery starts over with a full set of attempts
+UPDATE webhook_deliveries
+SET status = 'pending',
+  attempts = 0,
+  next_attempt_at = sqlc.arg(now)::timestamp,
+  updated_at = sqlc.arg(now)::timestamp
+WHERE id = sqlc.arg(id)
+  AND subscription_id = sqlc.arg(subscription_id)
+RETURNING *;
+-- name: DeleteWebhookDeliveriesBefore :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending'
+  AND created_at < $1;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN suspended_at TIMESTAMP,
+  ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '',
+  ADD COLUMN chirps_hidden BOOL NOT NULL DEFAULT FALSE,
+  ADD COLUMN password_reset_required BOOL NOT NULL DEFAULT FALSE;
+-- +goose Down
+ALTER TABLE users DROP COLUMN suspended_at,
+  DROP COLUMN suspension_reason,
+  DROP COLUMN chirps_hidden,
+  DROP COLUMN password_reset_required;
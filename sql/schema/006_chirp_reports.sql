@@ -0,0 +1,41 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP;
+CREATE TABLE chirp_reports(
+  id UUID PRIMARY KEY,
+  -- the chirp may get deleted, so its author & body are copied as evidence
+  chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+  chirp_author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_body TEXT NOT NULL,
+  reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason TEXT NOT NULL CHECK (
+    reason IN (
+      'spam',
+      'harassment',
+      'hate_speech',
+      'violence',
+      'sexual_content',
+      'misinformation',
+      'other'
+    )
+  ),
+  details TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  resolved_at TIMESTAMP,
+  UNIQUE(chirp_id, reporter_id)
+);
+CREATE INDEX chirp_reports_status_created_at_idx ON chirp_reports(status, created_at);
+CREATE TABLE moderation_actions(
+  id UUID PRIMARY KEY,
+  report_id UUID NOT NULL REFERENCES chirp_reports(id) ON DELETE CASCADE,
+  moderator_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  action TEXT NOT NULL CHECK (action IN ('dismiss', 'hide', 'delete', 'suspend')),
+  note TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE chirp_reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE content_filter_rules(
+  id UUID PRIMARY KEY,
+  pattern TEXT NOT NULL UNIQUE,
+  action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+-- the words cleanChirpContent used to hardcode
+INSERT INTO content_filter_rules(id, pattern, action, created_at, updated_at)
+VALUES (gen_random_uuid(), 'kerfuffle', 'mask', NOW(), NOW()),
+  (gen_random_uuid(), 'sharbert', 'mask', NOW(), NOW()),
+  (gen_random_uuid(), 'fornax', 'mask', NOW(), NOW());
+-- chirps flagged by the filter are queued as reports without a reporter
+ALTER TABLE chirp_reports
+ALTER COLUMN reporter_id DROP NOT NULL;
+ALTER TABLE chirp_reports DROP CONSTRAINT chirp_reports_reason_check;
+ALTER TABLE chirp_reports
+ADD CONSTRAINT chirp_reports_reason_check CHECK (
+    reason IN (
+      'spam',
+      'harassment',
+      'hate_speech',
+      'violence',
+      'sexual_content',
+      'misinformation',
+      'other',
+      'auto_flagged'
+    )
+  );
+-- +goose Down
+DELETE FROM chirp_reports
+WHERE reporter_id IS NULL;
+ALTER TABLE chirp_reports DROP CONSTRAINT chirp_reports_reason_check;
+ALTER TABLE chirp_reports
+ADD CONSTRAINT chirp_reports_reason_check CHECK (
+    reason IN (
+      'spam',
+      'harassment',
+      'hate_speech',
+      'violence',
+      'sexual_content',
+      'misinformation',
+      'other'
+    )
+  );
+ALTER TABLE chirp_reports
+ALTER COLUMN reporter_id SET NOT NULL;
+DROP TABLE content_filter_rules;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- every upload gets a new avatar_id, so the avatar URLs never change content and can be cached forever
+ALTER TABLE users
+ADD COLUMN avatar_id UUID UNIQUE,
+  ADD COLUMN avatar_content_type TEXT NOT NULL DEFAULT '';
+-- +goose Down
+ALTER TABLE users DROP COLUMN avatar_content_type,
+  DROP COLUMN avatar_id;
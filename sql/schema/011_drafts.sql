@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE drafts(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  body TEXT NOT NULL DEFAULT '',
+  -- media pre-uploaded to POST /api/media, attached when the draft is published
+  media_ids UUID[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX drafts_user_id_updated_at_idx ON drafts(user_id, updated_at);
+-- +goose Down
+DROP TABLE drafts;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- soft-deleted chirps stay around (for undo & moderation evidence) until the retention job purges them
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP,
+  ADD COLUMN deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX chirps_deleted_at_idx ON chirps(deleted_at)
+WHERE deleted_at IS NOT NULL;
+-- +goose Down
+DELETE FROM chirps
+WHERE deleted_at IS NOT NULL;
+DROP INDEX chirps_deleted_at_idx;
+ALTER TABLE chirps DROP COLUMN deleted_by,
+  DROP COLUMN deleted_at;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- bookmarks are private to their owner, nothing counts them publicly
+CREATE TABLE bookmarks(
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX bookmarks_user_id_created_at_idx ON bookmarks(user_id, created_at);
+-- +goose Down
+DROP TABLE bookmarks;
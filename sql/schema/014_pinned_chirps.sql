@@ -0,0 +1,6 @@
+-- +goose Up
+-- a user pins at most one of their chirps, shown first on their profile
+ALTER TABLE users
+ADD COLUMN pinned_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+-- +goose Down
+ALTER TABLE users DROP COLUMN pinned_chirp_id;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- a block hides the blocker's chirps from the blocked user (and stops any interaction between them),
+-- a mute only hides the muted user's chirps from the muter's timeline
+CREATE TABLE user_blocks(
+  blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (blocker_id, blocked_id)
+);
+CREATE INDEX user_blocks_blocked_id_idx ON user_blocks(blocked_id);
+CREATE TABLE user_mutes(
+  muter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  muted_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (muter_id, muted_id)
+);
+-- +goose Down
+DROP TABLE user_mutes;
+DROP TABLE user_blocks;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- words & phrases hiding matching chirps from one user's reads, matched like the content filter rules
+CREATE TABLE muted_words(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  phrase TEXT NOT NULL,
+  -- null mutes the phrase until it's removed
+  expires_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  UNIQUE (user_id, phrase)
+);
+-- +goose Down
+DROP TABLE muted_words;
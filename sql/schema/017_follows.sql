@@ -0,0 +1,17 @@
+-- +goose Up
+-- a protected account's chirps are only visible to its accepted followers
+ALTER TABLE users
+ADD COLUMN is_protected BOOL NOT NULL DEFAULT FALSE;
+-- following a protected account starts as a pending request the account approves or denies
+CREATE TABLE follows(
+  follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'accepted')),
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (follower_id, followee_id)
+);
+CREATE INDEX follows_followee_id_status_idx ON follows(followee_id, status);
+-- +goose Down
+DROP TABLE follows;
+ALTER TABLE users DROP COLUMN is_protected;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- one conversation per pair of users, user_a being the smaller id so the pair is stored one way only
+CREATE TABLE conversations(
+  id UUID PRIMARY KEY,
+  user_a UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_b UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  -- time of the last message, conversations are listed by it
+  updated_at TIMESTAMP NOT NULL,
+  UNIQUE (user_a, user_b),
+  CHECK (user_a < user_b)
+);
+CREATE INDEX conversations_user_b_idx ON conversations(user_b);
+CREATE TABLE messages(
+  id UUID PRIMARY KEY,
+  conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+  sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  body TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  -- read receipt, set when the other participant reads the conversation
+  read_at TIMESTAMP
+);
+CREATE INDEX messages_conversation_id_created_at_idx ON messages(conversation_id, created_at);
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversations;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- events pushed to the users' real-time streams, kept for a while so a client can resume from its Last-Event-ID
+CREATE TABLE stream_events(
+  id BIGSERIAL PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX stream_events_user_id_id_idx ON stream_events(user_id, id);
+CREATE INDEX stream_events_created_at_idx ON stream_events(created_at);
+-- every replica listens on the channel, the notification is sent when the inserting transaction commits
+-- +goose StatementBegin
+CREATE FUNCTION notify_stream_event() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('stream_events', NEW.id || ':' || NEW.user_id);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+CREATE TRIGGER stream_events_notify
+AFTER INSERT ON stream_events
+FOR EACH ROW EXECUTE FUNCTION notify_stream_event();
+-- +goose Down
+DROP TABLE stream_events;
+DROP FUNCTION notify_stream_event();
@@ -0,0 +1,17 @@
+-- +goose Up
+-- in-app notifications, created in the background by the job runner
+CREATE TABLE notifications(
+  id UUID PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  -- follow | follow_request | follow_accepted | chirpy_red
+  type TEXT NOT NULL,
+  -- the user who triggered it, NULL for the system ones (Chirpy Red upgrade)
+  actor_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  read_at TIMESTAMP
+);
+CREATE INDEX notifications_user_id_created_at_idx ON notifications(user_id, created_at);
+CREATE INDEX notifications_unread_idx ON notifications(user_id)
+WHERE read_at IS NULL;
+-- +goose Down
+DROP TABLE notifications;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- periodic activity digest by email, off until the user opts in
+ALTER TABLE users
+ADD COLUMN digest_frequency TEXT NOT NULL DEFAULT 'off' CHECK (digest_frequency IN ('off', 'daily', 'weekly')),
+  ADD COLUMN digest_sent_at TIMESTAMP,
+  -- sent in the unsubscribe link of every digest, the link works without logging in
+  ADD COLUMN digest_unsubscribe_token TEXT UNIQUE;
+CREATE INDEX users_digest_due_idx ON users(digest_sent_at)
+WHERE digest_frequency <> 'off';
+-- +goose Down
+DROP INDEX users_digest_due_idx;
+ALTER TABLE users DROP COLUMN digest_unsubscribe_token,
+  DROP COLUMN digest_sent_at,
+  DROP COLUMN digest_frequency;
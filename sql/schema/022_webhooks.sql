@@ -0,0 +1,36 @@
+-- +goose Up
+-- outbound webhooks => a subscription gets the events of its owner, or of every user when user_id is NULL (admin only)
+CREATE TABLE webhook_subscriptions(
+  id UUID PRIMARY KEY,
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  url TEXT NOT NULL,
+  -- HMAC-SHA256 signing secret, only shown when the subscription is created
+  secret TEXT NOT NULL,
+  events TEXT[] NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX webhook_subscriptions_user_id_idx ON webhook_subscriptions(user_id);
+-- delivery log, a delivery is retried with exponential backoff until it succeeds or runs out of attempts
+CREATE TABLE webhook_deliveries(
+  id UUID PRIMARY KEY,
+  subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  event TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed')),
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  -- outcome of the last attempt => the receiver's answer, or the error when it couldn't be reached
+  last_status_code INT,
+  last_response TEXT NOT NULL DEFAULT '',
+  last_error TEXT NOT NULL DEFAULT '',
+  delivered_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX webhook_deliveries_subscription_id_created_at_idx ON webhook_deliveries(subscription_id, created_at);
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries(next_attempt_at)
+WHERE status = 'pending';
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- the reports & moderation actions are an audit trail, deleting an account must not erase them
+ALTER TABLE chirp_reports
+ALTER COLUMN chirp_author_id DROP NOT NULL;
+ALTER TABLE chirp_reports DROP CONSTRAINT chirp_reports_chirp_author_id_fkey;
+ALTER TABLE chirp_reports
+ADD CONSTRAINT chirp_reports_chirp_author_id_fkey FOREIGN KEY (chirp_author_id) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE chirp_reports DROP CONSTRAINT chirp_reports_reporter_id_fkey;
+ALTER TABLE chirp_reports
+ADD CONSTRAINT chirp_reports_reporter_id_fkey FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE moderation_actions
+ALTER COLUMN moderator_id DROP NOT NULL;
+ALTER TABLE moderation_actions DROP CONSTRAINT moderation_actions_moderator_id_fkey;
+ALTER TABLE moderation_actions
+ADD CONSTRAINT moderation_actions_moderator_id_fkey FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL;
+-- +goose Down
+DELETE FROM moderation_actions
+WHERE moderator_id IS NULL;
+ALTER TABLE moderation_actions DROP CONSTRAINT moderation_actions_moderator_id_fkey;
+ALTER TABLE moderation_actions
+ADD CONSTRAINT moderation_actions_moderator_id_fkey FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE moderation_actions
+ALTER COLUMN moderator_id SET NOT NULL;
+ALTER TABLE chirp_reports DROP CONSTRAINT chirp_reports_reporter_id_fkey;
+ALTER TABLE chirp_reports
+ADD CONSTRAINT chirp_reports_reporter_id_fkey FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE;
+DELETE FROM chirp_reports
+WHERE chirp_author_id IS NULL;
+ALTER TABLE chirp_reports DROP CONSTRAINT chirp_reports_chirp_author_id_fkey;
+ALTER TABLE chirp_reports
+ADD CONSTRAINT chirp_reports_chirp_author_id_fkey FOREIGN KEY (chirp_author_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE chirp_reports
+ALTER COLUMN chirp_author_id SET NOT NULL;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- the Last-Modified of the avatar, updated_at changes with any edit of the profile
+ALTER TABLE users
+ADD COLUMN avatar_updated_at TIMESTAMP;
+UPDATE users
+SET avatar_updated_at = updated_at
+WHERE avatar_id IS NOT NULL;
+-- +goose Down
+ALTER TABLE users DROP COLUMN avatar_updated_at;
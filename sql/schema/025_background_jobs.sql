@@ -0,0 +1,15 @@
+-- +goose Up
+-- background work queued by the requests (notifications, fan-outs), kept until it succeeds so a crash doesn't lose it
+CREATE TABLE background_jobs(
+  id UUID PRIMARY KEY,
+  kind TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  -- pushed back by the lease while a worker runs the job, then by the backoff when it fails
+  run_at TIMESTAMP NOT NULL,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX background_jobs_run_at_idx ON background_jobs(run_at);
+-- +goose Down
+DROP TABLE background_jobs;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- double opt-in of the digest: it's only sent to an address confirmed from a link sent there
+ALTER TABLE users
+ADD COLUMN digest_confirmed_email TEXT,
+  -- the frequency asked for, applied once the address is confirmed
+  ADD COLUMN digest_pending_frequency TEXT CHECK (digest_pending_frequency IN ('daily', 'weekly')),
+  ADD COLUMN digest_confirmation_token TEXT UNIQUE,
+  -- a new confirmation email waits for a cooldown, so the endpoint can't flood someone's inbox
+  ADD COLUMN digest_confirmation_sent_at TIMESTAMP;
+-- the addresses of the current subscribers were never confirmed, they opt in again
+UPDATE users
+SET digest_frequency = 'off'
+WHERE digest_frequency <> 'off';
+-- +goose Down
+ALTER TABLE users DROP COLUMN digest_confirmation_sent_at,
+  DROP COLUMN digest_confirmation_token,
+  DROP COLUMN digest_pending_frequency,
+  DROP COLUMN digest_confirmed_email;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- a claimed digest is leased until it's sent, digest_sent_at is only set once the delivery succeeded
+ALTER TABLE users
+ADD COLUMN digest_claimed_until TIMESTAMP;
+-- +goose Down
+ALTER TABLE users DROP COLUMN digest_claimed_until;